@@ -5,16 +5,27 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 var (
 	ErrNotSlice       = errors.New("should be a slice")
+	ErrNotMap         = errors.New("should be a map with string keys")
 	ErrNotSettable    = errors.New("should be settable")
 	ErrPrefixRequired = errors.New("prefix is required")
 )
 
+const (
+	sliceLenKey    = "LEN"
+	sliceDeleteKey = "DELETE"
+
+	// maxSliceLen limits length of slice set by `${prefix}_LEN`, as every missing element is allocated
+	maxSliceLen = 10000
+)
+
 var camelCaseRegex = regexp.MustCompile("(^[A-Za-z])|_([A-Za-z])")
 
 // ToCamelCase converts string to camel case
@@ -24,8 +35,8 @@ func ToCamelCase(str string) string {
 	})
 }
 
-// applyEnvOverridesToSlice merges elements of slice with ENV
-func applyEnvOverridesToSlice(prefix string, dst interface{}) error {
+// applyEnvOverridesToSlice merges elements of slice stored under `path` with ENV, elements of `secret` slice are redacted in errors
+func (l *loader) applyEnvOverridesToSlice(path, prefix string, secret bool, dst interface{}) error {
 	if prefix == "" {
 		return ErrPrefixRequired
 	}
@@ -50,90 +61,175 @@ func applyEnvOverridesToSlice(prefix string, dst interface{}) error {
 		return fmt.Errorf("not settable type: %s %s at prefix: %s: %v", rv.Type(), riv.Type(), prefix, ErrNotSettable)
 	}
 
-	parseKeyVal, err := regexp.Compile(prefix + "_(\\d+)_(\\w+)=(.+)")
+	parseKeyVal, err := regexp.Compile("^" + regexp.QuoteMeta(prefix) + "_(\\d+)_(\\w+)=(.+)")
 	if err != nil {
 		return err
 	}
 
+	var errs MultiError
+
 	sliceOf := rit.Elem()
 	mapConfigs := make(map[int]reflect.Value)
-	envs := environ()
+	envs := l.env.Environ()
+	elementPath := func(i int) string {
+		return fmt.Sprintf("%s[%d]", path, i)
+	}
 
 	if !isZero(rv) {
-		l := riv.Len()
-		for i := 0; i < l; i++ {
-			value := reflect.Indirect(riv.Index(i))
-			// set defaults for element, it was created after first defaults was applied
-			if err := applyDefaultToEmpty(reflect.StructField{}, value); err != nil {
-				return err
+		n := riv.Len()
+		for i := 0; i < n; i++ {
+			value := reflect.New(sliceOf).Elem()
+			value.Set(riv.Index(i))
+			if value.Kind() == reflect.Ptr && value.IsNil() {
+				value.Set(reflect.New(sliceOf.Elem()))
 			}
+			// set defaults for element, it was created after first defaults was applied
+			errs = errs.append(l.applyElementDefaults(elementPath(i), reflect.Indirect(value), secret))
 			mapConfigs[i] = value
 		}
 	}
 
-	for _, keyVal := range envs {
-		if strings.HasPrefix(keyVal, prefix) {
-			matches := parseKeyVal.FindStringSubmatch(keyVal)
-			if len(matches) != 4 {
-				continue
-			}
+	newElement := func(i int) reflect.Value {
+		value := reflect.New(sliceOf).Elem()
+		if value.Kind() == reflect.Ptr {
+			value.Set(reflect.New(sliceOf.Elem()))
+		}
+		// set defaults for new created element
+		errs = errs.append(l.applyElementDefaults(elementPath(i), reflect.Indirect(value), secret))
+		return value
+	}
 
-			index, err := strconv.ParseInt(matches[1], 10, 64)
-			if err != nil {
-				return err
+	// `${prefix}_LEN` truncates slice or extends it with new elements
+	lenKey := prefix + "_" + sliceLenKey
+	resized := false
+	if value, found := l.lookupEnv(lenKey); found {
+		n, err := strconv.ParseUint(value, 10, 32)
+		switch {
+		case err != nil:
+			errs = errs.append(&FieldError{Path: path, Source: SourceEnv, Key: lenKey, Value: value,
+				Err: fmt.Errorf("failed to parse value %q as length of slice", value)})
+		case n > maxSliceLen:
+			errs = errs.append(&FieldError{Path: path, Source: SourceEnv, Key: lenKey, Value: value,
+				Err: fmt.Errorf("length of slice %d exceeds limit of %d elements", n, maxSliceLen)})
+		default:
+			resized = true
+			for i := range mapConfigs {
+				if i >= int(n) {
+					delete(mapConfigs, i)
+				}
 			}
-
-			i := int(index)
-			envKey := matches[2]
-			value := matches[3]
-
-			if _, ok := mapConfigs[i]; !ok {
-				value := reflect.Indirect(reflect.New(sliceOf))
-				// set defaults for new created element
-				if err := applyDefaultToEmpty(reflect.StructField{}, value); err != nil {
-					return err
+			for i := 0; i < int(n); i++ {
+				if _, ok := mapConfigs[i]; !ok {
+					mapConfigs[i] = newElement(i)
 				}
-				mapConfigs[i] = value
 			}
+		}
+	}
 
-			v := mapConfigs[i]
+	deleted := make(map[int]bool)
+	hasDeleteField := hasElementKey(indirectType(sliceOf), sliceDeleteKey)
+	elementPrefix := envPrefix{prefix: prefix, elemOf: indirectType(sliceOf), slice: true}
 
-			f, ok := fieldByEnvconfig(v, envKey)
-			if !ok {
-				// fallback in case field with
-				name := ToCamelCase(matches[2])
-				f = v.FieldByName(name)
-				if (f == reflect.Value{}) {
-					return fmt.Errorf("field %s not found", name)
-				}
-			}
+	for _, keyVal := range envs {
+		matches := parseKeyVal.FindStringSubmatch(keyVal)
+		if len(matches) != 4 {
+			continue
+		}
+
+		key := keyVal[:strings.Index(keyVal, "=")]
+		index, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			l.usedEnv[key] = true
+			errs = errs.append(&FieldError{Path: path, Source: SourceEnv, Key: key, Value: matches[3], Err: err})
+			continue
+		}
+
+		i := int(index)
 
-			if err := setValue(f, value); err != nil {
-				return err
+		// `${prefix}_${index}_DELETE` removes element, unless element has such field
+		if matches[2] == sliceDeleteKey && !hasDeleteField {
+			l.usedEnv[key] = true
+			if isTrue(matches[3]) {
+				deleted[i] = true
 			}
+			continue
+		}
+
+		if _, ok := mapConfigs[i]; !ok {
+			mapConfigs[i] = newElement(i)
 		}
 	}
 
-	if len(mapConfigs) == 0 {
-		return nil
+	// element, which is not in slice, could not be removed
+	for i := range deleted {
+		if _, ok := mapConfigs[i]; !ok {
+			key := fmt.Sprintf("%s_%d_%s", prefix, i, sliceDeleteKey)
+			errs = errs.append(&FieldError{Path: elementPath(i), Source: SourceEnv, Key: key,
+				Err: fmt.Errorf("element %d to delete not found", i)})
+			delete(deleted, i)
+		}
+	}
+
+	indexes := make([]int, 0, len(mapConfigs))
+	for i := range mapConfigs {
+		indexes = append(indexes, i)
 	}
+	sort.Ints(indexes)
 
-	values := make([]reflect.Value, len(mapConfigs))
-	for i, item := range mapConfigs {
-		values[i] = item
+	for _, i := range indexes {
+		errs = errs.append(l.applyEnvToElement(elementPath(i), fmt.Sprintf("%s_%d_", prefix, i), reflect.Indirect(mapConfigs[i]), secret))
 	}
 
+	for _, keyVal := range envs {
+		matches := parseKeyVal.FindStringSubmatch(keyVal)
+		if len(matches) != 4 {
+			continue
+		}
+		key := keyVal[:strings.Index(keyVal, "=")]
+		if l.usedEnv[key] || hasElementKey(indirectType(sliceOf), matches[2]) {
+			// fallback name shadowed by the first one is not unknown
+			continue
+		}
+
+		// key is reported once, enclosing slices would report it as well
+		l.usedEnv[key] = true
+		err := fmt.Errorf("field %s not found", ToCamelCase(matches[2]))
+		if suggestion := elementPrefix.suggest(matches[1]+"_", matches[2]); suggestion != "" {
+			err = fmt.Errorf("%s, did you mean %s?", err, suggestion)
+		}
+		// value is not reported, as mistyped ENV might hold a secret
+		errs = errs.append(&FieldError{Path: fmt.Sprintf("%s[%s]", path, matches[1]), Source: SourceEnv, Key: key, Err: err})
+	}
+
+	if len(mapConfigs) == 0 && !resized {
+		return errs.err()
+	}
+
+	values := make([]reflect.Value, 0, len(indexes))
+	moved := make(map[int]int, len(indexes))
+	for n, i := range indexes {
+		if n != i && !l.compactSlices {
+			return errs.append(&FieldError{Path: path, Source: SourceEnv, Key: prefix,
+				Err: fmt.Errorf("sparse slice: element %d is missing, but %d is set", n, i)}).err()
+		}
+		if !deleted[i] {
+			moved[i] = len(values)
+			values = append(values, mapConfigs[i])
+		}
+	}
+	l.renumber(path, moved)
+
 	ptr := reflect.New(rit)
 	tmp := ptr.Elem()
 	tmp.Set(reflect.Append(tmp, values...))
 
 	if rv.CanSet() {
 		setPtrValue(rv, ptr, tmp)
-		return nil
+		return errs.err()
 	}
 
 	setPtrValue(riv, ptr, tmp)
-	return nil
+	return errs.err()
 }
 
 // setPtrValue set as Ptr or Value
@@ -145,36 +241,351 @@ func setPtrValue(dst, ptr, val reflect.Value) {
 	}
 }
 
-// fieldByEnvconfig lookup field by `envconfig` tag
-func fieldByEnvconfig(v reflect.Value, key string) (rv reflect.Value, ok bool) {
-	for i, l := 0, v.NumField(); i < l; i++ {
-		envconfig := v.Type().Field(i).Tag.Get(envConfigTag)
-		if key == envconfig {
-			return v.Field(i), true
+// applyEnvOverridesToMap merges elements of map stored under `path` with ENV,
+// keys of map are taken from ENV key between prefix and field name
+func (l *loader) applyEnvOverridesToMap(path, prefix string, secret bool, dst interface{}) error {
+	if prefix == "" {
+		return ErrPrefixRequired
+	}
+
+	rv, ok := dst.(reflect.Value)
+	if !ok {
+		// fallback
+		rv = reflect.ValueOf(dst)
+		if rv.Kind() != reflect.Ptr {
+			return ErrNotPointer
+		}
+	}
+
+	riv := indirectWalk(rv)
+	rit := indirectType(riv.Type())
+
+	if rit.Kind() != reflect.Map || rit.Key().Kind() != reflect.String {
+		return ErrNotMap
+	}
+
+	if !rv.CanSet() && !riv.CanSet() {
+		return fmt.Errorf("not settable type: %s %s at prefix: %s: %v", rv.Type(), riv.Type(), prefix, ErrNotSettable)
+	}
+
+	var errs MultiError
+
+	elemOf := rit.Elem()
+	mapConfigs := make(map[string]reflect.Value)
+	elementPath := func(key string) string {
+		return fmt.Sprintf("%s[%s]", path, key)
+	}
+
+	var keys []string
+	if riv.Kind() == reflect.Map && !riv.IsNil() {
+		iter := riv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			value := reflect.New(elemOf).Elem()
+			value.Set(iter.Value())
+			if value.Kind() == reflect.Ptr && value.IsNil() {
+				value.Set(reflect.New(elemOf.Elem()))
+			}
+			// set defaults for element, it was created after first defaults was applied
+			errs = errs.append(l.applyElementDefaults(elementPath(key), reflect.Indirect(value), secret))
+			mapConfigs[key] = value
+			keys = append(keys, key)
+		}
+	}
+
+	// longer keys first, so `ORDER_ITEMS` wins over `ORDER`
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	for _, keyVal := range l.env.Environ() {
+		name := keyVal[:strings.Index(keyVal, "=")]
+		if !strings.HasPrefix(name, prefix+"_") {
+			continue
+		}
+
+		key, ok := mapKeyOf(strings.TrimPrefix(name, prefix+"_"), keys, indirectType(elemOf))
+		if !ok {
+			continue
+		}
+
+		if _, ok := mapConfigs[key]; !ok {
+			value := reflect.New(elemOf).Elem()
+			if value.Kind() == reflect.Ptr {
+				value.Set(reflect.New(elemOf.Elem()))
+			}
+			// set defaults for new created element
+			errs = errs.append(l.applyElementDefaults(elementPath(key), reflect.Indirect(value), secret))
+			mapConfigs[key] = value
+			keys = append(keys, key)
 		}
 	}
-	return
+
+	if len(mapConfigs) == 0 {
+		return errs.err()
+	}
+
+	// keys are sorted, so errors are reported in the same order
+	sort.Strings(keys)
+
+	ptr := reflect.New(rit)
+	tmp := ptr.Elem()
+	tmp.Set(reflect.MakeMapWithSize(rit, len(mapConfigs)))
+
+	for _, key := range keys {
+		value := mapConfigs[key]
+		errs = errs.append(l.applyEnvToElement(elementPath(key), prefix+"_"+mapKeyToEnv(key)+"_", reflect.Indirect(value), secret))
+		tmp.SetMapIndex(reflect.ValueOf(key).Convert(rit.Key()), value)
+	}
+
+	errs = errs.append(l.unknownMapElementEnv(path, prefix, keys, indirectType(elemOf)))
+
+	if rv.CanSet() {
+		setPtrValue(rv, ptr, tmp)
+		return errs.err()
+	}
+
+	setPtrValue(riv, ptr, tmp)
+	return errs.err()
 }
 
-// applyDefaultToEmpty applies default to empty field only
-func applyDefaultToEmpty(t reflect.StructField, v reflect.Value) error {
-	if v.Kind() == reflect.Struct {
-		for i := 0; i < v.NumField(); i++ {
-			if err := applyDefaultToEmpty(v.Type().Field(i), v.Field(i)); err != nil {
-				return err
+// unknownMapElementEnv reports ENV of elements stored under `keys` of map, which match no field of element,
+// the same way as unknown fields of slice elements are reported. ENV, which matches no element, is left
+// to stray ENV check, as key of new element could not be told from unknown field
+func (l *loader) unknownMapElementEnv(path, prefix string, keys []string, elemOf reflect.Type) error {
+	var names []string
+	if l.root.IsValid() {
+		names, _ = envKeysOf(l.root.Type())
+	}
+
+	// longer keys first, so `ORDER_ITEMS` wins over `ORDER`
+	keys = append([]string(nil), keys...)
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	var errs MultiError
+
+	elementPrefix := envPrefix{prefix: prefix, elemOf: elemOf}
+	for _, keyVal := range l.env.Environ() {
+		name := keyVal[:strings.Index(keyVal, "=")]
+		if l.usedEnv[name] || !strings.HasPrefix(name, prefix+"_") || containsString(names, name) {
+			continue
+		}
+
+		for _, key := range keys {
+			element := mapKeyToEnv(key) + "_"
+			field := strings.TrimPrefix(name, prefix+"_"+element)
+			if len(field) == len(name) || field == "" {
+				continue
+			}
+			if hasElementKey(elemOf, field) {
+				// fallback name shadowed by the first one is not unknown
+				break
+			}
+
+			// key is reported once, enclosing slices and maps would report it as well
+			l.usedEnv[name] = true
+			err := fmt.Errorf("field %s not found", ToCamelCase(field))
+			if suggestion := elementPrefix.suggest(element, field); suggestion != "" {
+				err = fmt.Errorf("%s, did you mean %s?", err, suggestion)
+			}
+			errs = errs.append(&FieldError{Path: fmt.Sprintf("%s[%s]", path, key), Source: SourceEnv, Key: name, Err: err})
+			break
+		}
+	}
+
+	return errs.err()
+}
+
+// mapKeyOf splits ENV key to map key and field of element. Existing map keys are preferred,
+// otherwise the shortest key followed by a known field of element is taken in lower case
+func mapKeyOf(envKey string, keys []string, elemOf reflect.Type) (string, bool) {
+	for _, key := range keys {
+		if strings.HasPrefix(envKey, mapKeyToEnv(key)+"_") {
+			return key, true
+		}
+	}
+
+	for i, r := range envKey {
+		if r != '_' || i == 0 {
+			continue
+		}
+		if hasElementKey(elemOf, envKey[i+1:]) {
+			return strings.ToLower(envKey[:i]), true
+		}
+	}
+
+	return "", false
+}
+
+// mapKeyToEnv converts map key to part of ENV key, e.g. `orders-db` to `ORDERS_DB`
+func mapKeyToEnv(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+}
+
+// hasElementKey reports whether ENV key relative to element of type `t` addresses any of its fields
+func hasElementKey(t reflect.Type, key string) bool {
+	if t.Kind() != reflect.Struct || isTime(t) {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if value, ok := f.Tag.Lookup(envPrefixTag); ok {
+			if kind := indirectType(f.Type).Kind(); kind == reflect.Slice || kind == reflect.Map {
+				if strings.HasPrefix(key, value+"_") {
+					return true
+				}
+				continue
 			}
 		}
+
+		if f.Type.Kind() == reflect.Struct && !isTime(f.Type) {
+			if prefix := toEnvKey(f.Name) + "_"; strings.HasPrefix(key, prefix) && hasElementKey(f.Type, key[len(prefix):]) {
+				return true
+			}
+			continue
+		}
+
+		names := append(envNames(f), toEnvKey(f.Name))
+		if containsString(names, key) || containsString(names, strings.TrimSuffix(key, fileEnvSuffix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyEnvToElement applies ENV prefixed by `prefix` to element of slice stored under `path`.
+// Fields are looked up by `envconfig` tag or by field name, nested structs are looked up
+// under the field name and nested slices under their own `envprefix`. `secret` is passed down from field tagged as secret
+func (l *loader) applyEnvToElement(path, prefix string, v reflect.Value, secret bool) error {
+	if v.Kind() != reflect.Struct || isTime(v.Type()) {
 		return nil
 	}
 
+	l.addFieldEnv(prefix, elementEnvKeys(v.Type()))
+
+	var errs MultiError
+
+	for i := 0; i < v.NumField(); i++ {
+		t, f := v.Type().Field(i), v.Field(i)
+		if t.PkgPath != "" {
+			continue
+		}
+
+		fieldPath, fieldSecret := joinPath(path, t.Name), secret || isSecretTag(t)
+
+		if value, ok := t.Tag.Lookup(envPrefixTag); ok {
+			switch indirectType(f.Type()).Kind() {
+			case reflect.Slice:
+				errs = errs.append(l.applyEnvOverridesToSlice(fieldPath, prefix+value, fieldSecret, f))
+				continue
+			case reflect.Map:
+				errs = errs.append(l.applyEnvOverridesToMap(fieldPath, prefix+value, fieldSecret, f))
+				continue
+			}
+		}
+
+		if f.Kind() == reflect.Struct && !isTime(f.Type()) {
+			errs = errs.append(l.applyEnvToElement(fieldPath, prefix+toEnvKey(t.Name)+"_", f, fieldSecret))
+			continue
+		}
+
+		names := envNames(t)
+		if name := toEnvKey(t.Name); !containsString(names, name) {
+			names = append(names, name)
+		}
+
+		errs = errs.append(l.setEnvField(fieldPath, prefix, names, t, f, fieldSecret))
+	}
+
+	return errs.err()
+}
+
+// toEnvKey converts field name to ENV key, e.g. `CAFile` to `CA_FILE`
+func toEnvKey(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
+
+// defaultField is a string field of element, which was set from default, `path` is path of `json` names
+type defaultField struct {
+	path string
+	v    reflect.Value
+}
+
+// applyElementDefaults applies defaults to element of slice or map, which is created after defaults were applied.
+// Only fields set from default are expanded, other fields were expanded once config file was read.
+// Element of `secret` slice or map is redacted in errors
+func (l *loader) applyElementDefaults(path string, v reflect.Value, secret bool) error {
+	var (
+		errs MultiError
+		set  []defaultField
+	)
+
+	root := l.root
+	if !root.IsValid() {
+		root = v
+	}
+
+	errs = errs.append(l.applyDefaultToEmpty(path, jsonPathOf(root.Type(), path), reflect.StructField{}, v, secret, &set))
+
+	if !l.expandVariables {
+		return errs.err()
+	}
+
+	e := l.expander
+	if e == nil {
+		e = l.newExpander(root)
+	}
+	for _, f := range set {
+		e.forget(f.path)
+		errs = errs.append(e.expandValues(f.path, f.v))
+	}
+	return errs.err()
+}
+
+// applyDefaultToEmpty applies default to empty field only, fields, which are set, are collected to `set`
+func (l *loader) applyDefaultToEmpty(path, jsonPath string, t reflect.StructField, v reflect.Value, secret bool, set *[]defaultField) error {
+	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
+		var errs MultiError
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			errs = errs.append(l.applyDefaultToEmpty(joinPath(path, f.Name), joinPath(jsonPath, jsonName(f)), f, v.Field(i),
+				secret || isSecretTag(f), set))
+		}
+		return errs.err()
+	}
+
 	if !isZero(v) {
 		return nil
 	}
 
-	value, ok := t.Tag.Lookup("default")
+	value, ok := t.Tag.Lookup(defaultTag)
 	if !ok {
 		return nil
 	}
 
-	return setValue(v, value)
+	if err := l.setDefault(path, t, v, value, secret); err != nil {
+		return err
+	}
+	*set = append(*set, defaultField{path: jsonPath, v: v})
+	return nil
 }
@@ -38,12 +38,48 @@ func TestToCamelCase(t *testing.T) {
 	}
 }
 
+func TestToEnvKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		value  string
+		expect string
+	}{
+		{
+			name:   "camel case",
+			value:  "AddrRenamed",
+			expect: "ADDR_RENAMED",
+		},
+		{
+			name:   "abbreviation",
+			value:  "CAFile",
+			expect: "CA_FILE",
+		},
+		{
+			name:   "upper case",
+			value:  "TLS",
+			expect: "TLS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := toEnvKey(tt.value)
+			if tt.expect != actual {
+				t.Errorf("mismatch %s %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
 func TestApplyEnvOverridesToSlice(t *testing.T) {
 	t.Parallel()
 
 	type Payload struct {
 		Addr    string        `envconfig:"ADDR"`
 		Timeout time.Duration `envconfig:"TIMEOUT"`
+		Host    string        `envconfig:"HOST,HOSTNAME"`
 	}
 
 	tests := []struct {
@@ -75,7 +111,8 @@ func TestApplyEnvOverridesToSlice(t *testing.T) {
 			prefix: "PREFIX_S",
 			value:  new([]Payload),
 			envs:   []string{"PREFIX_S_0_ADDR", "localhost", "PREFIX_S_0_TIMEOUT", "localhost"},
-			err:    errors.New(`failed to parse value "localhost" as time.Duration type`),
+			err: MultiError{&FieldError{Path: "Payloads[0].Timeout", Source: SourceEnv, Key: "PREFIX_S_0_TIMEOUT", Value: "localhost",
+				Err: errors.New(`failed to parse value "localhost" as time.Duration type`)}},
 		},
 		{
 			name:   "ok",
@@ -97,13 +134,273 @@ func TestApplyEnvOverridesToSlice(t *testing.T) {
 			expect: &[]Payload{{Addr: "localhost", Timeout: time.Minute}, {Timeout: 2 * time.Minute}, {Timeout: 30 * time.Minute}},
 			envs:   []string{"PREFIX_S_0_ADDR", "localhost", "PREFIX_S_0_TIMEOUT", "1m", "PREFIX_S_1_TIMEOUT", "2m"},
 		},
+		{
+			name:   "fallback name shadowed by the first one",
+			prefix: "PREFIX_S",
+			value:  new([]Payload),
+			expect: &[]Payload{{Host: "a"}},
+			envs:   []string{"PREFIX_S_0_HOST", "a", "PREFIX_S_0_HOSTNAME", "b"},
+		},
+		{
+			name:   "pointer elements",
+			prefix: "PREFIX_S",
+			value:  &[]*Payload{{Addr: "localhost"}, nil},
+			expect: &[]*Payload{{Addr: "localhost", Timeout: time.Minute}, {}, {Addr: "remote"}},
+			envs:   []string{"PREFIX_S_0_TIMEOUT", "1m", "PREFIX_S_2_ADDR", "remote"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newLoader(WithEnv(testEnv(tt.envs...))).applyEnvOverridesToSlice("Payloads", tt.prefix, false, tt.value)
+
+			assert.Equal(t, tt.err, err, tt.name)
+			if tt.err != nil && err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.expect, tt.value, tt.name)
+		})
+	}
+}
+
+func TestApplyEnvOverridesToSliceNested(t *testing.T) {
+	t.Parallel()
+
+	type Shard struct {
+		Addr string `envconfig:"ADDR"`
+		Port string `default:"5432"`
+	}
+
+	type TLS struct {
+		CAFile  string `envconfig:"CA_FILE"`
+		Enabled bool
+	}
+
+	type Payload struct {
+		Addr   string
+		TLS    TLS
+		Tags   []string
+		Shards []Shard `envprefix:"SHARDS"`
+	}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		expect interface{}
+		envs   []string
+		err    error
+	}{
+		{
+			name:   "nested struct",
+			value:  new([]Payload),
+			expect: &[]Payload{{Addr: "localhost", TLS: TLS{CAFile: "ca.pem", Enabled: true}}},
+			envs:   []string{"NESTED_0_ADDR", "localhost", "NESTED_0_TLS_CA_FILE", "ca.pem", "NESTED_0_TLS_ENABLED", "true"},
+		},
+		{
+			name:   "nested slices",
+			value:  &[]Payload{{Shards: []Shard{{Addr: "shard0", Port: "5433"}}}},
+			expect: &[]Payload{{Tags: []string{"a", "b"}, Shards: []Shard{{Addr: "shard0", Port: "5433"}, {Addr: "shard1", Port: "5432"}}}},
+			envs:   []string{"NESTED_0_TAGS", "a,b", "NESTED_0_SHARDS_1_ADDR", "shard1"},
+		},
+		{
+			name:   "nested defaults",
+			value:  new([]Payload),
+			expect: &[]Payload{{Shards: []Shard{{Addr: "localhost", Port: "5432"}}}},
+			envs:   []string{"NESTED_0_SHARDS_0_ADDR", "localhost"},
+		},
+		{
+			name:  "unknown nested field",
+			value: new([]Payload),
+			envs:  []string{"NESTED_0_SHARDS_0_USER", "postgres"},
+			err: MultiError{&FieldError{Path: "Payloads[0].Shards[0]", Source: SourceEnv, Key: "NESTED_0_SHARDS_0_USER",
+				Err: errors.New("field User not found")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newLoader(WithEnv(testEnv(tt.envs...))).applyEnvOverridesToSlice("Payloads", "NESTED", false, tt.value)
+
+			assert.Equal(t, tt.err, err, tt.name)
+			if tt.err != nil && err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.expect, tt.value, tt.name)
+		})
+	}
+}
+
+func TestApplyEnvOverridesToMap(t *testing.T) {
+	t.Parallel()
+
+	type Payload struct {
+		Host string `envconfig:"HOST" default:"localhost"`
+		Port string `envconfig:"PORT" default:"5432"`
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		value  interface{}
+		expect interface{}
+		envs   []string
+		err    error
+	}{
+		{
+			name: "no prefix",
+			err:  ErrPrefixRequired,
+		},
+		{
+			name:   "not a map",
+			prefix: "PREFIX_M",
+			value:  new([]Payload),
+			err:    ErrNotMap,
+		},
+		{
+			name:   "ok",
+			prefix: "PREFIX_M",
+			value:  new(map[string]Payload),
+			expect: new(map[string]Payload),
+		},
+		{
+			name:   "fill with env values",
+			prefix: "PREFIX_M",
+			value:  new(map[string]Payload),
+			expect: &map[string]Payload{
+				"orders":      {Host: "orders", Port: "5432"},
+				"order_items": {Host: "localhost", Port: "5433"},
+			},
+			envs: []string{"PREFIX_M_ORDERS_HOST", "orders", "PREFIX_M_ORDER_ITEMS_PORT", "5433", "PREFIX_M_UNKNOWN", "1"},
+		},
+		{
+			name:   "merge with env values",
+			prefix: "PREFIX_M",
+			value:  &map[string]*Payload{"users": {Host: "users"}, "Orders": {Port: "5433"}},
+			expect: &map[string]*Payload{"users": {Host: "users", Port: "5432"}, "Orders": {Host: "orders", Port: "5433"}},
+			envs:   []string{"PREFIX_M_ORDERS_HOST", "orders"},
+		},
+		{
+			name:   "unknown field of element",
+			prefix: "PREFIX_M",
+			value:  &map[string]Payload{"orders": {}},
+			envs:   []string{"PREFIX_M_ORDERS_HSOT", "orders", "PREFIX_M_USERS_HSOT", "users"},
+			err: MultiError{&FieldError{Path: "Payloads[orders]", Source: SourceEnv, Key: "PREFIX_M_ORDERS_HSOT",
+				Err: errors.New("field Hsot not found, did you mean PREFIX_M_ORDERS_HOST?")}},
+		},
+		{
+			name:   "fail on parse",
+			prefix: "PREFIX_M",
+			value:  new(map[string]struct{ Port int }),
+			envs:   []string{"PREFIX_M_ORDERS_PORT", "port"},
+			err: MultiError{&FieldError{Path: "Payloads[orders].Port", Source: SourceEnv, Key: "PREFIX_M_ORDERS_PORT", Value: "port",
+				Err: errors.New(`failed to parse value "port" as Int64 type`)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newLoader(WithEnv(testEnv(tt.envs...))).applyEnvOverridesToMap("Payloads", tt.prefix, false, tt.value)
+
+			assert.Equal(t, tt.err, err, tt.name)
+			if tt.err != nil && err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.expect, tt.value, tt.name)
+		})
+	}
+}
+
+func TestApplyEnvOverridesToSliceResize(t *testing.T) {
+	t.Parallel()
+
+	type Payload struct {
+		Addr string `envconfig:"ADDR" default:"localhost"`
+	}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		value  interface{}
+		expect interface{}
+		envs   []string
+		err    error
+	}{
+		{
+			name:  "sparse slice",
+			value: new([]Payload),
+			envs:  []string{"RESIZE_0_ADDR", "addr0", "RESIZE_2_ADDR", "addr2"},
+			err: MultiError{&FieldError{Path: "Payloads", Source: SourceEnv, Key: "RESIZE",
+				Err: errors.New("sparse slice: element 1 is missing, but 2 is set")}},
+		},
+		{
+			name:   "compact sparse slice",
+			opts:   []Option{WithCompactSlices()},
+			value:  new([]Payload),
+			expect: &[]Payload{{Addr: "addr0"}, {Addr: "addr2"}},
+			envs:   []string{"RESIZE_0_ADDR", "addr0", "RESIZE_2_ADDR", "addr2"},
+		},
+		{
+			name:   "truncate",
+			value:  &[]Payload{{Addr: "addr0"}, {Addr: "addr1"}, {Addr: "addr2"}},
+			expect: &[]Payload{{Addr: "addr0"}},
+			envs:   []string{"RESIZE_LEN", "1"},
+		},
+		{
+			name:   "extend",
+			value:  &[]Payload{{Addr: "addr0"}},
+			expect: &[]Payload{{Addr: "addr0"}, {Addr: "localhost"}, {Addr: "addr2"}},
+			envs:   []string{"RESIZE_LEN", "3", "RESIZE_2_ADDR", "addr2"},
+		},
+		{
+			name:  "invalid length",
+			value: new([]Payload),
+			envs:  []string{"RESIZE_LEN", "-1"},
+			err: MultiError{&FieldError{Path: "Payloads", Source: SourceEnv, Key: "RESIZE_LEN", Value: "-1",
+				Err: errors.New(`failed to parse value "-1" as length of slice`)}},
+		},
+		{
+			name:  "too long",
+			value: new([]Payload),
+			envs:  []string{"RESIZE_LEN", "4000000000"},
+			err: MultiError{&FieldError{Path: "Payloads", Source: SourceEnv, Key: "RESIZE_LEN", Value: "4000000000",
+				Err: errors.New("length of slice 4000000000 exceeds limit of 10000 elements")}},
+		},
+		{
+			name:  "exceeds limit",
+			value: new([]Payload),
+			envs:  []string{"RESIZE_LEN", "10001"},
+			err: MultiError{&FieldError{Path: "Payloads", Source: SourceEnv, Key: "RESIZE_LEN", Value: "10001",
+				Err: errors.New("length of slice 10001 exceeds limit of 10000 elements")}},
+		},
+		{
+			name:  "delete missing element",
+			value: &[]Payload{{Addr: "addr0"}},
+			envs:  []string{"RESIZE_3_DELETE", "true"},
+			err: MultiError{&FieldError{Path: "Payloads[3]", Source: SourceEnv, Key: "RESIZE_3_DELETE",
+				Err: errors.New("element 3 to delete not found")}},
+		},
+		{
+			name:   "extend pointer elements",
+			value:  &[]*Payload{{Addr: "addr0"}},
+			expect: &[]*Payload{{Addr: "addr0"}, {Addr: "localhost"}},
+			envs:   []string{"RESIZE_LEN", "2"},
+		},
+		{
+			name:   "delete",
+			value:  &[]Payload{{Addr: "addr0"}, {Addr: "addr1"}, {Addr: "addr2"}},
+			expect: &[]Payload{{Addr: "addr0"}, {Addr: "addr2"}},
+			envs:   []string{"RESIZE_1_DELETE", "true", "RESIZE_0_DELETE", "false"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			defer envs{}.set(tt.envs...).unset()
+			opts := append([]Option{WithEnv(testEnv(tt.envs...))}, tt.opts...)
 
-			err := applyEnvOverridesToSlice(tt.prefix, tt.value)
+			err := newLoader(opts...).applyEnvOverridesToSlice("Payloads", "RESIZE", false, tt.value)
 
 			assert.Equal(t, tt.err, err, tt.name)
 			if tt.err != nil && err != nil {
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change is a changed leaf field of config, values of secrets are redacted
+type Change struct {
+	// Path is path of field names, e.g. `Redis.Addrs` or `Postgres.Replicas[0].Host`
+	Path string
+	// Old is nil for added element of slice or map
+	Old interface{}
+	// New is nil for removed element of slice or map
+	New interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// ChangeHandler receives previous and new snapshots of config with changes between them
+type ChangeHandler func(old, new interface{}, changes []Change)
+
+type subscription struct {
+	path string
+	fn   ChangeHandler
+}
+
+// changesOf returns changed leaf fields of configs `old` and `new` of the same type in order of fields of `new`,
+// fields of removed elements of slices and maps go last
+func changesOf(old, new interface{}) []Change {
+	type leaf struct {
+		v      reflect.Value
+		secret bool
+	}
+
+	previous := make(map[string]leaf)
+	var previousPaths []string
+	walkLeaves("", reflect.StructField{}, reflect.ValueOf(old), false, func(path string, _ reflect.StructField, v reflect.Value, secret bool) {
+		previous[path] = leaf{v, secret}
+		previousPaths = append(previousPaths, path)
+	})
+
+	var changes []Change
+	seen := make(map[string]bool)
+	walkLeaves("", reflect.StructField{}, reflect.ValueOf(new), false, func(path string, _ reflect.StructField, v reflect.Value, secret bool) {
+		seen[path] = true
+		p, ok := previous[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, New: changeValue(v, secret)})
+		case !reflect.DeepEqual(leafInterface(p.v), leafInterface(v)):
+			changes = append(changes, Change{Path: path, Old: changeValue(p.v, p.secret), New: changeValue(v, secret)})
+		}
+	})
+	for _, path := range previousPaths {
+		if !seen[path] {
+			changes = append(changes, Change{Path: path, Old: changeValue(previous[path].v, previous[path].secret)})
+		}
+	}
+	return changes
+}
+
+// changeValue returns value of leaf for change, secret is redacted
+func changeValue(v reflect.Value, secret bool) interface{} {
+	if secret {
+		return redacted
+	}
+	return leafInterface(v)
+}
+
+func leafInterface(v reflect.Value) interface{} {
+	v = indirectWalk(v)
+	if !v.IsValid() || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		return nil
+	}
+	return v.Interface()
+}
+
+// matches reports whether field stored under `path` is the subscribed field or is nested in it
+func (s subscription) matches(path string) bool {
+	return s.path == "" || path == s.path ||
+		strings.HasPrefix(path, s.path+".") || strings.HasPrefix(path, s.path+"[")
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangesOf(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string
+		Port int
+	}
+
+	type Config struct {
+		Redis struct {
+			Addrs    []string
+			Password Secret
+			Timeout  time.Duration
+		}
+		Replicas []Replica
+		Pools    map[string]int
+		Token    *string `secret:"true"`
+		Creds    struct {
+			User  string
+			Roles []string
+		} `secret:"true"`
+	}
+
+	token := "s3cr3t"
+
+	var old, new Config
+	old.Redis.Addrs = []string{"a:6379"}
+	old.Redis.Password = "s3cr3t"
+	old.Redis.Timeout = time.Second
+	old.Replicas = []Replica{{Host: "a", Port: 1}, {Host: "b", Port: 2}}
+	old.Pools = map[string]int{"orders": 1, "users": 2}
+
+	new.Redis.Addrs = []string{"a:6379", "b:6379"}
+	new.Redis.Password = "n3w"
+	new.Redis.Timeout = time.Second
+	new.Replicas = []Replica{{Host: "a", Port: 3}}
+	new.Pools = map[string]int{"orders": 1, "users": 2}
+	new.Token = &token
+	new.Creds.User = "admin"
+	new.Creds.Roles = []string{"root"}
+
+	assert.Equal(t, []Change{
+		{Path: "Redis.Addrs", Old: []string{"a:6379"}, New: []string{"a:6379", "b:6379"}},
+		{Path: "Redis.Password", Old: redacted, New: redacted},
+		{Path: "Replicas[0].Port", Old: 1, New: 3},
+		{Path: "Token", Old: redacted, New: redacted},
+		{Path: "Creds.User", Old: redacted, New: redacted},
+		{Path: "Creds.Roles", Old: redacted, New: redacted},
+		{Path: "Replicas[1].Host", Old: "b"},
+		{Path: "Replicas[1].Port", Old: 2},
+	}, changesOf(&old, &new))
+
+	assert.Empty(t, changesOf(&old, &old))
+	assert.Equal(t, "Replicas[0].Port: 1 -> 3", Change{Path: "Replicas[0].Port", Old: 1, New: 3}.String())
+}
+
+func TestWatcherSubscribe(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		LogLevel string `json:"log_level"`
+		Redis    struct {
+			Addrs    []string `json:"addrs"`
+			Password Secret   `json:"password"`
+		} `json:"redis"`
+	}
+
+	dir, err := ioutil.TempDir("", "subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{
+		"config.json": `{"log_level": "info", "redis": {"addrs": ["a:6379"], "password": "s3cr3t"}}`,
+	})
+
+	var (
+		cfg    Config
+		calls  []string
+		errs   []error
+		redis  []Change
+		latest interface{}
+	)
+	w, err := NewWatcher(&cfg, filename, []Option{WithEnv(MapEnv{})}, WithErrorHook(func(err error) {
+		errs = append(errs, err)
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w.OnChange(func(old, new interface{}, changes []Change) {
+		calls = append(calls, "all")
+		assert.Equal(t, &cfg, old)
+		latest = new
+	})
+	w.Subscribe("LogLevel", func(old, new interface{}, changes []Change) {
+		calls = append(calls, "log level")
+		panic("broken subscriber")
+	})
+	w.Subscribe("Redis", func(old, new interface{}, changes []Change) {
+		calls = append(calls, "redis")
+		redis = changes
+	})
+	w.Subscribe("Redis.Addrs", func(old, new interface{}, changes []Change) {
+		calls = append(calls, "redis addrs")
+	})
+
+	helperWriteFiles(t, dir, map[string]string{
+		"config.json": `{"log_level": "debug", "redis": {"addrs": ["a:6379"], "password": "n3w"}}`,
+	})
+	if !assert.NoError(t, w.Reload()) {
+		return
+	}
+
+	assert.Equal(t, []string{"all", "log level", "redis"}, calls)
+	assert.Equal(t, []Change{{Path: "Redis.Password", Old: redacted, New: redacted}}, redis)
+	assert.Equal(t, w.Config(), latest)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], "subscriber of LogLevel panicked: broken subscriber")
+	}
+
+	calls = nil
+	assert.NoError(t, w.Reload())
+	assert.Empty(t, calls, "subscribers should not be notified without changes")
+
+	t.Run("panic without error hook", func(t *testing.T) {
+		var warnings []string
+		w, err := NewWatcher(&Config{}, filename, []Option{WithEnv(MapEnv{}), WithWarningHook(func(message string) {
+			warnings = append(warnings, message)
+		})})
+		if !assert.NoError(t, err) {
+			return
+		}
+		w.OnChange(func(old, new interface{}, changes []Change) {
+			panic("broken subscriber")
+		})
+
+		helperWriteFiles(t, dir, map[string]string{"config.json": `{"log_level": "error"}`})
+		assert.NoError(t, w.Reload())
+		assert.Equal(t, []string{"subscriber of changes panicked: broken subscriber"}, warnings)
+	})
+}
+
+// countingEnv returns the next number for every lookup, so every reload loads new config
+type countingEnv struct {
+	n int64
+}
+
+func (e *countingEnv) LookupEnv(key string) (string, bool) {
+	if key != "WATCH_RATE_LIMIT" {
+		return "", false
+	}
+	return strconv.FormatInt(atomic.AddInt64(&e.n, 1), 10), true
+}
+
+func (e *countingEnv) Environ() []string {
+	return nil
+}
+
+func TestWatcherNotifyOrder(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{}`})
+
+	var cfg watchedConfig
+	w, err := NewWatcher(&cfg, filename, []Option{WithEnv(&countingEnv{})})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var limits [][2]int
+	w.OnChange(func(old, new interface{}, changes []Change) {
+		// slow subscriber lets the next reload publish its snapshot meanwhile
+		time.Sleep(time.Millisecond)
+		limits = append(limits, [2]int{old.(*watchedConfig).RateLimit, new.(*watchedConfig).RateLimit})
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, w.Reload())
+		}()
+	}
+	wg.Wait()
+
+	if !assert.Len(t, limits, 20) {
+		return
+	}
+	for i := 1; i < len(limits); i++ {
+		assert.Equal(t, limits[i-1][1], limits[i][0], "changes should be notified in order of snapshots")
+	}
+	assert.Equal(t, w.Config().(*watchedConfig).RateLimit, limits[len(limits)-1][1])
+}
+
+func TestWatcherReloadBySubscriber(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{}`})
+
+	var cfg watchedConfig
+	w, err := NewWatcher(&cfg, filename, []Option{WithEnv(&countingEnv{})})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var limits [][2]int
+	w.OnChange(func(old, new interface{}, changes []Change) {
+		limits = append(limits, [2]int{old.(*watchedConfig).RateLimit, new.(*watchedConfig).RateLimit})
+		if len(limits) < 3 {
+			assert.NoError(t, w.Reload())
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, w.Reload())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload called by subscriber should not deadlock")
+	}
+
+	assert.Equal(t, [][2]int{{1, 2}, {2, 3}, {3, 4}}, limits)
+	assert.Equal(t, 4, w.Config().(*watchedConfig).RateLimit)
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
-	"os"
 	"path/filepath"
 	"reflect"
 	"strconv"
@@ -18,6 +18,12 @@ var (
 	ErrNotStruct  = errors.New("should be a structure")
 )
 
+// Validator is implemented by config structs, which validate themselves.
+// It is called once fields of struct are validated
+type Validator interface {
+	Validate() error
+}
+
 var (
 	unixEpochTime      = time.Unix(0, 0)
 	timeType           = reflect.TypeOf((*time.Time)(nil)).Elem()
@@ -26,13 +32,24 @@ var (
 )
 
 const (
-	envConfigTag = "envconfig"
-	envPrefixTag = "envprefix"
-	defaultTag   = "default"
+	envConfigTag  = "envconfig"
+	envPrefixTag  = "envprefix"
+	defaultTag    = "default"
+	deprecatedTag = "deprecated"
+	envJSONTag    = "envjson"
+	sepTag        = "sep"
+	trimTag       = "trim"
+	skipEmptyTag  = "skipempty"
+
+	fileEnvSuffix = "_FILE"
 )
 
 // Init reads and init configuration to `config` variable, which must be a reference of struct
-func Init(config interface{}, filename string) error {
+func Init(config interface{}, filename string, opts ...Option) error {
+	return newLoader(opts...).init(config, filename)
+}
+
+func (l *loader) init(config interface{}, filename string) error {
 	v := reflect.ValueOf(config)
 
 	if v.Kind() != reflect.Ptr {
@@ -45,66 +62,134 @@ func Init(config interface{}, filename string) error {
 		return ErrNotStruct
 	}
 
-	if err := applyDefault(reflect.StructField{}, v); err != nil {
-		return fmt.Errorf("init config with default values: %s", err)
+	l.root = v
+
+	names, _ := envKeysOf(v.Type())
+	l.addFieldEnv("", names)
+
+	if l.provenance != nil {
+		l.provenance.reset(v)
+	}
+
+	var errs MultiError
+
+	errs = errs.append(l.applyDefault("", reflect.StructField{}, v, false))
+
+	if err := l.applyJSONConfig(config, filename); err != nil {
+		return errs.append(err).err()
 	}
 
-	if err := applyJSONConfig(config, filename); err != nil {
-		return err
+	if l.expandVariables {
+		// expander is kept, so that values expanded once are not expanded by references of element defaults
+		l.expander = l.newExpander(v)
+		errs = errs.append(l.expander.expandValues("", v))
 	}
 
-	if err := applyEnv(v); err != nil {
-		return err
+	if l.secretsDir != "" {
+		errs = errs.append(l.applySecretsDir(v))
 	}
 
-	return validate(v)
+	errs = errs.append(l.applyEnv(v))
+
+	if l.strayEnv {
+		errs = errs.append(l.checkStrayEnv(v.Type()))
+	}
+
+	return errs.append(validate(v)).err()
 }
 
+// validate reports all fields, which failed validation
 func validate(v reflect.Value) error {
-	t := v.Type()
+	return validateField(reflect.Value{}, "", reflect.StructField{}, v).err()
+}
 
-	var invalidFields []string
+// validateField validates field `v` of struct `parent` stored under `path`, rules referring other fields
+// are resolved relative to `parent`. Nested structs are validated before `Validator` of enclosing struct is called
+func validateField(parent reflect.Value, path string, t reflect.StructField, v reflect.Value) (errs MultiError) {
+	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			errs = errs.append(validateField(v, joinPath(path, f.Name), f, v.Field(i)))
+		}
+		errs = errs.append(checkRules(parent, path, t, v))
+		return errs.append(callValidator(path, v))
+	}
 
-	for i := 0; i < v.NumField(); i++ {
-		invalidFields = append(invalidFields, validateField(t.Field(i), v.Field(i))...)
+	if value, ok := t.Tag.Lookup(requiredTag); ok && isTrue(value) && isZero(v) {
+		return errs.append(&FieldError{Path: path, Source: SourceValidate, Key: requiredTag, Err: ErrRequired})
 	}
 
-	if len(invalidFields) > 0 {
-		return fmt.Errorf("required fields: %v are not filled up. Please check configuration", invalidFields)
+	errs = errs.append(checkRules(parent, path, t, v))
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Struct && !isTime(v.Elem().Type()) {
+			errs = errs.append(validateField(parent, path, reflect.StructField{}, v.Elem()))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = errs.append(validateElement(fmt.Sprintf("%s[%d]", path, i), v.Index(i)))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			errs = errs.append(validateElement(fmt.Sprintf("%s[%v]", path, iter.Key()), iter.Value()))
+		}
 	}
 
-	return nil
+	return errs
 }
 
-func validateField(t reflect.StructField, v reflect.Value) (invalidFields []string) {
-	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
-		for i := 0; i < v.NumField(); i++ {
-			invalidFields = append(invalidFields, validateField(v.Type().Field(i), v.Field(i))...)
-		}
-		return invalidFields
+// validateElement validates element of slice or map, if it is a struct
+func validateElement(path string, v reflect.Value) MultiError {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || isTime(v.Type()) {
+		return nil
 	}
+	return validateField(reflect.Value{}, path, reflect.StructField{}, v)
+}
 
-	value, ok := t.Tag.Lookup("required")
-	if !ok || !isTrue(value) {
-		return invalidFields
+// callValidator calls `Validator` implemented by value or pointer to value
+func callValidator(path string, v reflect.Value) error {
+	if !v.CanAddr() && v.CanInterface() {
+		// values of maps are not addressable, so pointer receiver is called on copy
+		value := reflect.New(v.Type()).Elem()
+		value.Set(v)
+		v = value
 	}
 
-	if isZero(v) {
-		invalidFields = append(invalidFields, t.Name)
+	var validator Validator
+	if v.CanAddr() {
+		validator, _ = v.Addr().Interface().(Validator)
+	}
+	if validator == nil && v.CanInterface() {
+		validator, _ = v.Interface().(Validator)
+	}
+	if validator == nil {
+		return nil
 	}
 
-	return invalidFields
+	if err := validator.Validate(); err != nil {
+		return &FieldError{Path: path, Source: SourceValidate, Err: err}
+	}
+	return nil
 }
 
-// applyDefault recursively sets values to default
-func applyDefault(t reflect.StructField, v reflect.Value) error {
+// applyDefault recursively sets values to default, `path` is path of field names.
+// `secret` is passed down from field tagged as secret to its nested fields
+func (l *loader) applyDefault(path string, t reflect.StructField, v reflect.Value, secret bool) error {
 	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
+		var errs MultiError
 		for i := 0; i < v.NumField(); i++ {
-			if err := applyDefault(v.Type().Field(i), v.Field(i)); err != nil {
-				return err
-			}
+			f := v.Type().Field(i)
+			errs = errs.append(l.applyDefault(joinPath(path, f.Name), f, v.Field(i), secret || isSecretTag(f)))
 		}
-		return nil
+		return errs.err()
 	}
 
 	value, ok := t.Tag.Lookup(defaultTag)
@@ -112,11 +197,35 @@ func applyDefault(t reflect.StructField, v reflect.Value) error {
 		return nil
 	}
 
-	return setValue(v, value)
+	return l.setDefault(path, t, v, value, secret)
+}
+
+// setDefault expands and sets default value of field stored under `path`, value of `secret` is redacted in error
+func (l *loader) setDefault(path string, t reflect.StructField, v reflect.Value, value string, secret bool) error {
+	expanded, err := l.expandDefault(v, value)
+	if err == nil {
+		err = setValue(t, v, expanded)
+	}
+	if err != nil {
+		return redactFieldError(t, v.Type(), secret, &FieldError{Path: path, Source: SourceDefault, Value: value, Err: err})
+	}
+	l.record(path, SourceDefault, "")
+	return nil
+}
+
+// expandDefault expands ENV in default of non string field, string values are expanded once config is built
+func (l *loader) expandDefault(v reflect.Value, value string) (string, error) {
+	if !l.expandVariables || !strings.Contains(value, "${") {
+		return value, nil
+	}
+	if t := indirectType(v.Type()); t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String {
+		return value, nil
+	}
+	return l.expandEnv(value)
 }
 
-// setValue sets value depend on type
-func setValue(v reflect.Value, value string) error {
+// setValue sets value depend on type, `t` describes format of value
+func setValue(t reflect.StructField, v reflect.Value, value string) error {
 	switch indirectType(v.Type()) {
 	case timeType:
 		return setTime(&v, value)
@@ -130,7 +239,7 @@ func setValue(v reflect.Value, value string) error {
 	case reflect.String:
 		v.SetString(value)
 	case reflect.Slice:
-		setSlice(&v, value)
+		setSlice(&v, value, listFormatOf(t))
 	case reflect.Int, reflect.Int32, reflect.Int64:
 		return setInt(&v, value)
 	case reflect.Uint, reflect.Uint32, reflect.Uint64:
@@ -142,60 +251,177 @@ func setValue(v reflect.Value, value string) error {
 	return nil
 }
 
-func applyJSONConfig(config interface{}, filename string) error {
-	if len(filename) == 0 {
-		return nil
+func (l *loader) applyEnv(v reflect.Value) error {
+	return l.applyEnvValue("", reflect.StructField{}, v, false)
+}
+
+// applyEnvValue applies ENV to field stored under `path`, `path` is path of field names.
+// `secret` is passed down from field tagged as secret to its nested fields and elements
+func (l *loader) applyEnvValue(path string, t reflect.StructField, v reflect.Value, secret bool) error {
+	var errs MultiError
+
+	// whole value is applied first, so that `envprefix` and nested ENV override it
+	if isComposite(v.Type()) {
+		errs = errs.append(l.setEnvField(path, "", envNames(t), t, v, secret))
 	}
 
-	file, err := os.Open(filepath.Clean(filename))
-	if err != nil {
-		return err
+	switch indirectType(v.Type()).Kind() {
+	case reflect.Slice:
+		if value, ok := t.Tag.Lookup(envPrefixTag); ok {
+			return errs.append(l.applyEnvOverridesToSlice(path, value, secret, v)).err()
+		}
+	case reflect.Map:
+		if value, ok := t.Tag.Lookup(envPrefixTag); ok {
+			return errs.append(l.applyEnvOverridesToMap(path, value, secret, v)).err()
+		}
+	}
+
+	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			errs = errs.append(l.applyEnvValue(joinPath(path, f.Name), f, v.Field(i), secret || isSecretTag(f)))
+		}
+		return errs.err()
+	}
+
+	if _, ok := t.Tag.Lookup(envConfigTag); !ok || isComposite(v.Type()) {
+		return errs.err()
 	}
-	defer file.Close()
 
-	return json.NewDecoder(file).Decode(config)
+	return l.setEnvField(path, "", envNames(t), t, v, secret)
 }
 
-func applyEnv(v reflect.Value) error {
-	for i := 0; i < v.NumField(); i++ {
-		err := applyEnvValue(v.Type().Field(i), v.Field(i))
+// setEnvField sets value of the first found ENV of `names` to field stored under `path`.
+// Names listed in `deprecated` tag are still applied but reported through warning hook, value of `secret` is redacted in error
+func (l *loader) setEnvField(path, prefix string, names []string, t reflect.StructField, v reflect.Value, secret bool) error {
+	key, value, found, err := l.lookupEnvNames(prefix, names, splitTagNames(t.Tag.Get(deprecatedTag)))
+	if err == nil && found {
+		err = setEnvValue(t, v, value)
+	}
+	if err != nil {
+		return redactFieldError(t, v.Type(), secret, &FieldError{Path: path, Source: SourceEnv, Key: key, Value: value, Err: err})
+	}
+	if found {
+		l.record(path, SourceEnv, key)
+	}
+	return nil
+}
+
+// lookupEnvNames looks up ENV names in order of declaration and returns the name, which value is taken from
+func (l *loader) lookupEnvNames(prefix string, names, deprecated []string) (string, string, bool, error) {
+	for _, name := range names {
+		key, value, found, err := l.lookupEnvOrFile(prefix + name)
 		if err != nil {
-			return err
+			return key, "", false, err
+		}
+		if !found {
+			continue
 		}
+		if containsString(deprecated, name) {
+			l.warnDeprecated(prefix, name, names, deprecated)
+		}
+		return key, value, true, nil
 	}
 
-	return nil
+	return "", "", false, nil
 }
 
-func applyEnvValue(t reflect.StructField, v reflect.Value) error {
-	switch indirectType(v.Type()).Kind() {
-	case reflect.Slice:
-		if value, ok := t.Tag.Lookup(envPrefixTag); ok {
-			return applyEnvOverridesToSlice(value, v)
+// lookupEnvOrFile looks up ENV `key` or reads value from file, which path is stored in ENV `${key}_FILE`.
+// File is not read, when `${key}_FILE` is ENV of another field, e.g. of `CAFile` next to `CA`.
+// The name of ENV, which value is taken from, is returned as well
+func (l *loader) lookupEnvOrFile(key string) (string, string, bool, error) {
+	value, found := l.lookupEnv(key)
+
+	if l.fieldEnv[key+fileEnvSuffix] {
+		return key, value, found, nil
+	}
+
+	filename, fileFound := l.lookupEnv(key + fileEnvSuffix)
+	if !fileFound {
+		return key, value, found, nil
+	}
+	if found {
+		return key, "", false, fmt.Errorf("%s%s is set as well", key, fileEnvSuffix)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return key + fileEnvSuffix, "", false, err
+	}
+
+	return key + fileEnvSuffix, strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// addFieldEnv remembers ENV of fields, so that they are not taken for `_FILE` ENV of other fields
+func (l *loader) addFieldEnv(prefix string, names []string) {
+	for _, name := range names {
+		l.fieldEnv[prefix+name] = true
+	}
+}
+
+// lookupEnv looks up ENV and remembers found keys
+func (l *loader) lookupEnv(key string) (string, bool) {
+	value, found := l.env.LookupEnv(key)
+	if found {
+		l.usedEnv[key] = true
+	}
+	return value, found
+}
+
+func (l *loader) warnDeprecated(prefix, name string, names, deprecated []string) {
+	for _, replacement := range names {
+		if !containsString(deprecated, replacement) {
+			l.warn("env %s%s is deprecated, use %s%s instead", prefix, name, prefix, replacement)
+			return
 		}
 	}
+	l.warn("env %s%s is deprecated", prefix, name)
+}
 
-	if v.Kind() == reflect.Struct && !isTime(v.Type()) {
-		for i := 0; i < v.NumField(); i++ {
-			err := applyEnvValue(v.Type().Field(i), v.Field(i))
-			if err != nil {
-				return err
-			}
+// envNames returns names from `envconfig` tag followed by `deprecated` ones which are not listed there
+func envNames(t reflect.StructField) []string {
+	names := splitTagNames(t.Tag.Get(envConfigTag))
+	for _, name := range splitTagNames(t.Tag.Get(deprecatedTag)) {
+		if !containsString(names, name) {
+			names = append(names, name)
 		}
-		return nil
 	}
+	return names
+}
 
-	value, ok := t.Tag.Lookup(envConfigTag)
-	if !ok {
-		return nil
+func splitTagNames(tag string) (names []string) {
+	for _, name := range strings.Split(tag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
 	}
+	return names
+}
 
-	value, found := lookupEnv(value)
-	if !found {
-		return nil
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
+}
 
-	return setValue(v, value)
+// setEnvValue sets value of ENV, which is decoded as JSON for `envjson` fields
+// or for composite fields, when value looks like JSON object or array
+func setEnvValue(t reflect.StructField, v reflect.Value, value string) error {
+	if isTrue(t.Tag.Get(envJSONTag)) || isComposite(v.Type()) && isJSON(value) {
+		return setJSON(v, value)
+	}
+	return setValue(t, v, value)
+}
+
+// setJSON decodes value the same way as config file does
+func setJSON(v reflect.Value, value string) error {
+	if err := json.Unmarshal([]byte(value), v.Addr().Interface()); err != nil {
+		return fmt.Errorf("failed to parse value %q as JSON of %s type: %s", value, v.Type(), err)
+	}
+	return nil
 }
 
 func setTime(v *reflect.Value, value string) error {
@@ -260,12 +486,12 @@ func setBool(v *reflect.Value, value string) {
 	v.SetBool(isTrue(value))
 }
 
-func setSlice(v *reflect.Value, value string) {
+func setSlice(v *reflect.Value, value string, format listFormat) {
 	if _, ok := v.Interface().([]string); !ok {
 		return
 	}
 
-	values := strings.Split(value, ",")
+	values := format.split(value)
 	slice := reflect.MakeSlice(reflect.TypeOf([]string{}), len(values), len(values))
 
 	for i, value := range values {
@@ -275,10 +501,114 @@ func setSlice(v *reflect.Value, value string) {
 	v.Set(slice)
 }
 
+// listFormat describes how list is stored in a single string
+type listFormat struct {
+	sep       string
+	trim      bool
+	skipEmpty bool
+}
+
+func listFormatOf(t reflect.StructField) listFormat {
+	format := listFormat{
+		sep:       t.Tag.Get(sepTag),
+		trim:      isTrue(t.Tag.Get(trimTag)),
+		skipEmpty: isTrue(t.Tag.Get(skipEmptyTag)),
+	}
+	if format.sep == "" {
+		format.sep = ","
+	}
+	return format
+}
+
+// split splits value by separator. Separator, quote and backslash could be escaped by backslash,
+// element could be quoted CSV-style, e.g. `"a,b",c` or `"say ""hi"""`
+func (f listFormat) split(value string) []string {
+	var (
+		values    []string
+		element   strings.Builder
+		quoted    bool
+		wasQuoted bool
+	)
+
+	flush := func() {
+		item := element.String()
+		if f.trim && !wasQuoted {
+			item = strings.TrimSpace(item)
+		}
+		if item != "" || !f.skipEmpty {
+			values = append(values, item)
+		}
+		element.Reset()
+		wasQuoted = false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value) && (value[i+1] == '\\' || value[i+1] == '"'):
+			i++
+			element.WriteByte(value[i])
+		case c == '\\' && strings.HasPrefix(value[i+1:], f.sep):
+			element.WriteString(f.sep)
+			i += len(f.sep)
+		case quoted:
+			if c != '"' {
+				element.WriteByte(c)
+			} else if i+1 < len(value) && value[i+1] == '"' {
+				element.WriteByte(c)
+				i++
+			} else {
+				quoted = false
+			}
+		case c == '"' && !wasQuoted && strings.TrimSpace(element.String()) == "":
+			element.Reset()
+			quoted, wasQuoted = true, true
+		case strings.HasPrefix(value[i:], f.sep):
+			flush()
+			i += len(f.sep) - 1
+		case wasQuoted && f.trim && (c == ' ' || c == '\t'):
+			// skip spaces after closing quote
+		default:
+			element.WriteByte(c)
+		}
+	}
+	flush()
+
+	return values
+}
+
+// join joins values by separator, so that split returns them back
+func (f listFormat) join(values []string) string {
+	escaped := make([]string, 0, len(values))
+	for _, value := range values {
+		escaped = append(escaped, strings.NewReplacer(`\`, `\\`, `"`, `\"`, f.sep, `\`+f.sep).Replace(value))
+	}
+	return strings.Join(escaped, f.sep)
+}
+
 func isTrue(value string) bool {
 	return value == "1" || strings.ToLower(value) == "true"
 }
 
+func isJSON(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")
+}
+
+// isComposite reports whether type has no plain text representation in ENV
+func isComposite(t reflect.Type) bool {
+	t = indirectType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		return !isTime(t)
+	case reflect.Map:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.String
+	}
+	return false
+}
+
 func isTime(t reflect.Type) bool {
 	return indirectType(t) == timeType
 }
@@ -315,6 +645,26 @@ func isZero(v reflect.Value) bool {
 	return reflect.DeepEqual(v.Interface(), zero.Interface())
 }
 
+// walkFields calls `fn` for every field of `v` except structs, which are walked recursively.
+// `path` is path of field names, `jsonPath` is path of `json` names, `secret` is passed down from field tagged as secret
+func walkFields(path, jsonPath string, t reflect.StructField, v reflect.Value, secret bool,
+	fn func(path, jsonPath string, t reflect.StructField, v reflect.Value, secret bool) error) error {
+	if v.Kind() != reflect.Struct || isTime(v.Type()) {
+		return fn(path, jsonPath, t, v, secret)
+	}
+
+	var errs MultiError
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		errs = errs.append(walkFields(joinPath(path, f.Name), joinPath(jsonPath, jsonName(f)), f, v.Field(i), secret || isSecretTag(f), fn))
+	}
+
+	return errs.err()
+}
+
 // indirectWalk walks through Value to the last Value in chain
 func indirectWalk(v reflect.Value) (rv reflect.Value) {
 	for ; v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface; v = v.Elem() {
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -61,22 +64,20 @@ func TestInit(t *testing.T) {
 			name:     "invalid config file content",
 			filePath: "config_test.go",
 			cfg:      &cfg,
-			error:    "invalid character 'p' looking for beginning of value",
+			error:    "file config_test.go:1:1: invalid character 'p' looking for beginning of value\n\tpackage config\n\t^",
 		},
 		{
 			name:     "wrong env type",
 			filePath: "./testdata/config.json",
 			cfg:      &cfg,
 			envs:     []string{"INIT_POOL_SIZE", "1.0"},
-			error:    `failed to parse value "1.0" as Uint64 type`,
+			error:    `PoolSize: env INIT_POOL_SIZE: failed to parse value "1.0" as Uint64 type`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			defer envs{}.set(tt.envs...).unset()
-
-			err := Init(tt.cfg, tt.filePath)
+			err := Init(tt.cfg, tt.filePath, WithEnv(testEnv(tt.envs...)))
 
 			if tt.error != "" {
 				assert.EqualError(t, err, tt.error, tt.name)
@@ -108,7 +109,7 @@ func TestApplyEnvironment(t *testing.T) {
 	e := reflect.TypeOf(cfg).Elem()
 	v := reflect.ValueOf(cfg).Elem()
 
-	defer envs{}.set(
+	env := testEnv(
 		"ENV_NAME", "def_name",
 		"ENV_PASS", "def_pass",
 		"ENV_AGE", "18",
@@ -119,13 +120,13 @@ func TestApplyEnvironment(t *testing.T) {
 		"ENV_ID", "1",
 		"ENV_EXISTS", "false",
 		"ENV_ABSENT", "true",
-	).unset()
+	)
 
-	err := applyEnv(v)
+	err := newLoader(WithEnv(env)).applyEnv(v)
 	assert.NoError(t, err)
 
 	for i := 0; i < v.NumField(); i++ {
-		helperAssertFieldEnvironment(t, e.Field(i), v.Field(i))
+		helperAssertFieldEnvironment(t, env, e.Field(i), v.Field(i))
 	}
 }
 
@@ -150,7 +151,7 @@ func TestApplyDefaultValues(t *testing.T) {
 	e := reflect.TypeOf(cfg).Elem()
 	v := reflect.ValueOf(cfg).Elem()
 
-	err := applyDefault(reflect.StructField{}, v)
+	err := newLoader().applyDefault("", reflect.StructField{}, v, false)
 	assert.NoError(t, err)
 
 	for i := 0; i < v.NumField(); i++ {
@@ -189,7 +190,7 @@ func TestApplyDefaultValues(t *testing.T) {
 		},
 		{
 			name:  "fail to parse int",
-			error: `failed to parse value "age" as Int64 type`,
+			error: `User.Age: default: failed to parse value "age" as Int64 type`,
 			payload: func() (reflect.StructField, reflect.Value) {
 				type test struct {
 					User struct {
@@ -206,7 +207,7 @@ func TestApplyDefaultValues(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			typ, val := tt.payload()
-			err := applyDefault(typ, val)
+			err := newLoader().applyDefault(typ.Name, typ, val, false)
 			if tt.error != "" {
 				assert.EqualError(t, err, tt.error)
 				return
@@ -238,7 +239,7 @@ func TestApplyEnvValues(t *testing.T) {
 		},
 		{
 			name:  "fail to parse int",
-			error: `failed to parse value "age" as Int64 type`,
+			error: `User.Age: env age: failed to parse value "age" as Int64 type`,
 			payload: func() (reflect.StructField, reflect.Value) {
 				type test struct {
 					User struct {
@@ -294,18 +295,312 @@ func TestApplyEnvValues(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			typ, val := tt.payload()
-			defer envs{}.set(tt.envs...).unset()
+			err := newLoader(WithEnv(testEnv(tt.envs...))).applyEnvValue(typ.Name, typ, val, false)
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestApplyEnvFallbackNames(t *testing.T) {
+	t.Parallel()
+
+	type Payload struct {
+		Addr string `envconfig:"FALLBACK_ADDR,FALLBACK_HOST" deprecated:"FALLBACK_HOST"`
+		Port string `envconfig:"FALLBACK_PORT" deprecated:"FALLBACK_OLD_PORT"`
+	}
+
+	var cfg struct {
+		Payload  Payload
+		Replicas []Payload `envprefix:"FALLBACK_REPLICAS"`
+	}
+
+	tests := []struct {
+		name     string
+		envs     []string
+		expect   Payload
+		replicas []Payload
+		warnings []string
+	}{
+		{
+			name:   "new names",
+			envs:   []string{"FALLBACK_ADDR", "new", "FALLBACK_HOST", "old", "FALLBACK_PORT", "1"},
+			expect: Payload{Addr: "new", Port: "1"},
+		},
+		{
+			name:   "deprecated names",
+			envs:   []string{"FALLBACK_HOST", "old", "FALLBACK_OLD_PORT", "2"},
+			expect: Payload{Addr: "old", Port: "2"},
+			warnings: []string{
+				"env FALLBACK_HOST is deprecated, use FALLBACK_ADDR instead",
+				"env FALLBACK_OLD_PORT is deprecated, use FALLBACK_PORT instead",
+			},
+		},
+		{
+			name:     "deprecated names in slice",
+			envs:     []string{"FALLBACK_REPLICAS_0_FALLBACK_HOST", "replica"},
+			replicas: []Payload{{Addr: "replica"}},
+			warnings: []string{
+				"env FALLBACK_REPLICAS_0_FALLBACK_HOST is deprecated, use FALLBACK_REPLICAS_0_FALLBACK_ADDR instead",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Payload, cfg.Replicas = Payload{}, nil
+
+			var warnings []string
+			err := Init(&cfg, "", WithEnv(testEnv(tt.envs...)), WithWarningHook(func(message string) {
+				warnings = append(warnings, message)
+			}))
 
-			err := applyEnvValue(typ, val)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, cfg.Payload)
+			assert.Equal(t, tt.replicas, cfg.Replicas)
+			assert.Equal(t, tt.warnings, warnings)
+		})
+	}
+}
+
+func TestApplyEnvJSON(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host    string   `json:"host"    envconfig:"JSON_HOST"`
+		Timeout Duration `json:"timeout" envconfig:"JSON_TIMEOUT"`
+	}
+
+	type Config struct {
+		Replicas []Replica        `envconfig:"JSON_REPLICAS" envprefix:"JSON_REPLICAS"`
+		Limits   map[string]int   `envconfig:"JSON_LIMITS"`
+		Primary  Replica          `envconfig:"JSON_PRIMARY"`
+		Tags     []string         `envconfig:"JSON_TAGS"    envjson:"true"`
+		Hosts    []string         `envconfig:"JSON_HOSTS"`
+		Pools    map[string]*Pool `envconfig:"JSON_POOLS"`
+	}
+
+	tests := []struct {
+		name   string
+		envs   []string
+		expect Config
+		error  string
+	}{
+		{
+			name: "decode JSON",
+			envs: []string{
+				"JSON_REPLICAS", `[{"host":"replica0","timeout":"1s"},{"host":"replica1"}]`,
+				"JSON_LIMITS", `{"orders":10}`,
+				"JSON_PRIMARY", `{"host":"primary","timeout":"2s"}`,
+				"JSON_TAGS", `["a,b","c"]`,
+				"JSON_HOSTS", `[a,b`,
+			},
+			expect: Config{
+				Replicas: []Replica{{Host: "replica0", Timeout: Duration(time.Second)}, {Host: "replica1"}},
+				Limits:   map[string]int{"orders": 10},
+				Primary:  Replica{Host: "primary", Timeout: Duration(2 * time.Second)},
+				Tags:     []string{"a,b", "c"},
+				Hosts:    []string{"[a", "b"},
+			},
+		},
+		{
+			name: "override JSON by nested ENV",
+			envs: []string{
+				"JSON_REPLICAS", `[{"host":"replica0"}]`,
+				"JSON_REPLICAS_0_HOST", "replica",
+				"JSON_PRIMARY", `{"host":"primary"}`,
+				"JSON_HOST", "host",
+			},
+			expect: Config{
+				Replicas: []Replica{{Host: "replica"}},
+				Primary:  Replica{Host: "host"},
+			},
+		},
+		{
+			name:  "invalid JSON",
+			envs:  []string{"JSON_POOLS", `{"orders":{"size":"1"}}`},
+			error: `failed to parse value "{\"orders\":{\"size\":\"1\"}}" as JSON of map[string]*config.Pool type`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := Init(&cfg, "", WithEnv(testEnv(tt.envs...)))
+			if tt.error != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.error)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, cfg)
+		})
+	}
+}
+
+type Pool struct {
+	Size int `json:"size"`
+}
+
+func TestListFormatSplit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format listFormat
+		value  string
+		expect []string
+	}{
+		{
+			name:   "comma",
+			format: listFormat{sep: ","},
+			value:  "a, b,,c",
+			expect: []string{"a", " b", "", "c"},
+		},
+		{
+			name:   "empty",
+			format: listFormat{sep: ","},
+			value:  "",
+			expect: []string{""},
+		},
+		{
+			name:   "custom separator",
+			format: listFormat{sep: ";;"},
+			value:  "a,b;;c",
+			expect: []string{"a,b", "c"},
+		},
+		{
+			name:   "escaping",
+			format: listFormat{sep: ","},
+			value:  `a\,b,c\\,\d`,
+			expect: []string{"a,b", `c\`, `\d`},
+		},
+		{
+			name:   "quoting",
+			format: listFormat{sep: ","},
+			value:  `"postgres://host?a=1,b=2", "say ""hi""",x"y"`,
+			expect: []string{"postgres://host?a=1,b=2", `say "hi"`, `x"y"`},
+		},
+		{
+			name:   "trim and skip empty",
+			format: listFormat{sep: ",", trim: true, skipEmpty: true},
+			value:  ` a , " b " ,, ,c`,
+			expect: []string{"a", " b ", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.format.split(tt.value))
+		})
+	}
+}
+
+func TestApplyListFormat(t *testing.T) {
+	t.Parallel()
+
+	var cfg struct {
+		Patterns []string `envconfig:"LIST_PATTERNS" default:"^a\\d$;^b$" sep:";"`
+		Hosts    []string `envconfig:"LIST_HOSTS"    default:" a, b ,"     trim:"true" skipempty:"true"`
+	}
+
+	err := Init(&cfg, "", WithEnv(MapEnv{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`^a\d$`, "^b$"}, cfg.Patterns)
+	assert.Equal(t, []string{"a", "b"}, cfg.Hosts)
+
+	err = Init(&cfg, "", WithEnv(MapEnv{"LIST_PATTERNS": `^(a|b)$;"^c;d$"`, "LIST_HOSTS": "c ,,d"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"^(a|b)$", "^c;d$"}, cfg.Patterns)
+	assert.Equal(t, []string{"c", "d"}, cfg.Hosts)
+}
+
+func TestApplyEnvFromFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	password := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(password, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	type Config struct {
+		Password string                      `envconfig:"FILE_PASSWORD"`
+		Replicas []struct{ Password string } `envprefix:"FILE_REPLICAS"`
+	}
+
+	tests := []struct {
+		name   string
+		envs   []string
+		expect string
+		error  string
+	}{
+		{
+			name:   "read file",
+			envs:   []string{"FILE_PASSWORD_FILE", password, "FILE_REPLICAS_0_PASSWORD_FILE", password},
+			expect: "secret",
+		},
+		{
+			name:  "both set",
+			envs:  []string{"FILE_PASSWORD", "secret", "FILE_PASSWORD_FILE", password},
+			error: "Password: env FILE_PASSWORD: FILE_PASSWORD_FILE is set as well",
+		},
+		{
+			name:  "missing file",
+			envs:  []string{"FILE_PASSWORD_FILE", filepath.Join(dir, "missing")},
+			error: "Password: env FILE_PASSWORD_FILE: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := Init(&cfg, "", WithEnv(testEnv(tt.envs...)))
 			if tt.error != "" {
 				assert.EqualError(t, err, tt.error)
 				return
 			}
 			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, cfg.Password)
+			assert.Equal(t, tt.expect, cfg.Replicas[0].Password)
 		})
 	}
 }
 
+func TestApplyEnvFromFileCollision(t *testing.T) {
+	t.Parallel()
+
+	type TLS struct {
+		CA     string
+		CAFile string
+	}
+
+	var cfg struct {
+		CA       string `envconfig:"COLLISION_CA"`
+		CAFile   string `envconfig:"COLLISION_CA_FILE"`
+		Replicas []TLS  `envprefix:"COLLISION_REPLICAS"`
+	}
+
+	err := Init(&cfg, "", WithEnv(testEnv(
+		"COLLISION_CA", "pem",
+		"COLLISION_CA_FILE", "/etc/ssl/ca.pem",
+		"COLLISION_REPLICAS_0_CA_FILE", "/etc/ssl/replica.pem",
+	)))
+	assert.NoError(t, err)
+	assert.Equal(t, "pem", cfg.CA)
+	assert.Equal(t, "/etc/ssl/ca.pem", cfg.CAFile)
+	assert.Equal(t, []TLS{{CAFile: "/etc/ssl/replica.pem"}}, cfg.Replicas)
+}
+
 func TestValidateField(t *testing.T) {
 	t.Parallel()
 
@@ -335,7 +630,7 @@ func TestValidateField(t *testing.T) {
 	e := reflect.TypeOf(data).Elem()
 	v := reflect.ValueOf(data).Elem()
 
-	invalidFields := validateField(e.Field(0), v.Field(0))
+	invalidFields := validateField(v, "User", e.Field(0), v.Field(0))
 	assert.Len(t, invalidFields, 9)
 
 	err := validate(v)
@@ -358,10 +653,10 @@ func helperAssertFieldDefaultValue(t *testing.T, f reflect.StructField, v reflec
 	helperAssert(t, f, v, value)
 }
 
-func helperAssertFieldEnvironment(t *testing.T, f reflect.StructField, v reflect.Value) {
+func helperAssertFieldEnvironment(t *testing.T, env Env, f reflect.StructField, v reflect.Value) {
 	if v.Kind() == reflect.Struct {
 		for i := 0; i < v.NumField(); i++ {
-			helperAssertFieldEnvironment(t, v.Type().Field(i), v.Field(i))
+			helperAssertFieldEnvironment(t, env, v.Type().Field(i), v.Field(i))
 		}
 		return
 	}
@@ -371,7 +666,7 @@ func helperAssertFieldEnvironment(t *testing.T, f reflect.StructField, v reflect
 		return
 	}
 
-	value, _ = lookupEnv(value)
+	value, _ = env.LookupEnv(value)
 
 	helperAssert(t, f, v, value)
 }
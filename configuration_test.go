@@ -40,7 +40,12 @@ func TestInitOnConfiguration(t *testing.T) {
 			value:  &Configuration{},
 			expect: &Configuration{},
 			envs:   []string{"APP_0_ADDR", "localhost", "APP_0_TIMEOUT", "localhost"},
-			err:    errors.New(`failed to parse value "localhost" as time.Duration type`),
+			err: MultiError{
+				&FieldError{Path: "Payload[0].Timeout", Source: SourceEnv, Key: "APP_0_TIMEOUT", Value: "localhost",
+					Err: errors.New(`failed to parse value "localhost" as time.Duration type`)},
+				&FieldError{Path: "PayloadPtr[0].Timeout", Source: SourceEnv, Key: "APP_0_TIMEOUT", Value: "localhost",
+					Err: errors.New(`failed to parse value "localhost" as time.Duration type`)},
+			},
 		},
 		{
 			name:   "ok",
@@ -81,9 +86,7 @@ func TestInitOnConfiguration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			defer envs{}.set(tt.envs...).unset()
-
-			err := Init(tt.value, "")
+			err := Init(tt.value, "", WithEnv(testEnv(tt.envs...)))
 
 			assert.Equal(t, tt.err, err, tt.name)
 			if tt.err != nil && err != nil {
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	descTag  = "desc"
+	usageTag = "usage"
+
+	// sliceIndexPattern and mapKeyPattern stand for index and key in ENV of elements of `envprefix` slices and maps
+	sliceIndexPattern = "{N}"
+	mapKeyPattern     = "{KEY}"
+)
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatUsage    Format = "usage"
+)
+
+// EnvVar describes ENV of config field
+type EnvVar struct {
+	// Name is ENV, names of elements of `envprefix` slices and maps have patterns `{N}` and `{KEY}`,
+	// e.g. `REPLICAS_{N}_HOST`
+	Name string
+	// Alternatives are fallback and deprecated names
+	Alternatives []string
+	// Path is path of field names, e.g. `Postgres.Replicas[{N}].Host`
+	Path        string
+	Type        string
+	Default     string
+	Required    bool
+	Secret      bool
+	Description string
+}
+
+// EnvVars returns ENV of fields of config in order they are applied, `config` is a struct or a pointer to struct.
+// Description is taken from `desc` or `usage` tag
+func EnvVars(config interface{}) []EnvVar {
+	t := reflect.TypeOf(config)
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil
+	}
+
+	var vars []EnvVar
+	envVarsOf("", "", false, reflect.StructField{}, indirectType(t), &vars)
+	return vars
+}
+
+// envVarsOf collects ENV of field `t` of type `typ` stored under `path` the same way `applyEnvValue` applies them.
+// Fields of elements are named after `prefix` by `envconfig` tag or field name as `applyEnvToElement` does
+func envVarsOf(path, prefix string, element bool, t reflect.StructField, typ reflect.Type, vars *[]EnvVar) {
+	// whole value is applied first, so that `envprefix` and nested ENV override it
+	if !element && isComposite(typ) {
+		addEnvVar(path, prefix, envNames(t), t, typ, vars)
+	}
+
+	if value, ok := t.Tag.Lookup(envPrefixTag); ok {
+		switch ft := indirectType(typ); ft.Kind() {
+		case reflect.Slice:
+			envVarsOf(path+"["+sliceIndexPattern+"]", prefix+value+"_"+sliceIndexPattern+"_", true,
+				reflect.StructField{}, indirectType(ft.Elem()), vars)
+			return
+		case reflect.Map:
+			envVarsOf(path+"["+mapKeyPattern+"]", prefix+value+"_"+mapKeyPattern+"_", true,
+				reflect.StructField{}, indirectType(ft.Elem()), vars)
+			return
+		}
+	}
+
+	if typ.Kind() == reflect.Struct && !isTime(typ) {
+		// nested struct of element is named after field, element itself has no field
+		if element && t.Name != "" {
+			prefix += toEnvKey(t.Name) + "_"
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if f := typ.Field(i); f.PkgPath == "" {
+				envVarsOf(joinPath(path, f.Name), prefix, element, f, f.Type, vars)
+			}
+		}
+		return
+	}
+
+	if element {
+		// elements of `envprefix` slices and maps, which are not structs, have no ENV
+		if t.Name == "" {
+			return
+		}
+		names := envNames(t)
+		if name := toEnvKey(t.Name); !containsString(names, name) {
+			names = append(names, name)
+		}
+		addEnvVar(path, prefix, names, t, typ, vars)
+		return
+	}
+
+	if _, ok := t.Tag.Lookup(envConfigTag); ok && !isComposite(typ) {
+		addEnvVar(path, prefix, envNames(t), t, typ, vars)
+	}
+}
+
+func addEnvVar(path, prefix string, names []string, t reflect.StructField, typ reflect.Type, vars *[]EnvVar) {
+	if len(names) == 0 {
+		return
+	}
+
+	v := EnvVar{
+		Name:        prefix + names[0],
+		Path:        path,
+		Type:        envTypeName(t, typ),
+		Default:     t.Tag.Get(defaultTag),
+		Required:    isRequired(t),
+		Secret:      isSecret(t, typ),
+		Description: t.Tag.Get(descTag),
+	}
+	for _, name := range names[1:] {
+		v.Alternatives = append(v.Alternatives, prefix+name)
+	}
+	if v.Description == "" {
+		v.Description = t.Tag.Get(usageTag)
+	}
+	if v.Secret && v.Default != "" {
+		v.Default = redacted
+	}
+	*vars = append(*vars, v)
+}
+
+// envTypeName describes format of ENV of field `t` of type `typ`
+func envTypeName(t reflect.StructField, typ reflect.Type) string {
+	switch typ = indirectType(typ); {
+	case typ == timeType:
+		return "RFC3339 time"
+	case typ == durationType, typ == durationCustomType:
+		return "duration"
+	case isTrue(t.Tag.Get(envJSONTag)), isComposite(typ):
+		return "JSON"
+	case typ.Kind() == reflect.Slice:
+		return fmt.Sprintf("list separated by %q", listFormatOf(t).sep)
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	}
+	return typ.Kind().String()
+}
+
+// isRequired reports whether field is required by `required` tag
+func isRequired(t reflect.StructField) bool {
+	return isTrue(t.Tag.Get(requiredTag))
+}
+
+// Docs generates reference of ENV of config as Markdown table, plain text usage or `.env.example` file
+func Docs(config interface{}, format Format) ([]byte, error) {
+	if t := reflect.TypeOf(config); t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config should be a struct or a pointer to struct, got %T", config)
+	}
+
+	vars := EnvVars(config)
+
+	var b bytes.Buffer
+	switch format {
+	case FormatMarkdown:
+		writeMarkdownDocs(&b, vars)
+	case FormatUsage:
+		writeUsageDocs(&b, vars)
+	case FormatEnv:
+		writeEnvExample(&b, vars)
+	default:
+		return nil, fmt.Errorf("unsupported format of docs %q", format)
+	}
+	return b.Bytes(), nil
+}
+
+func writeMarkdownDocs(b *bytes.Buffer, vars []EnvVar) {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+	code := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return "`" + escape(value) + "`"
+	}
+
+	b.WriteString("| ENV | Type | Default | Required | Description |\n")
+	b.WriteString("|-----|------|---------|----------|-------------|\n")
+	for _, v := range vars {
+		description := escape(v.Description)
+		if len(v.Alternatives) > 0 {
+			if description != "" && !strings.HasSuffix(description, ".") {
+				description += "."
+			}
+			description = strings.TrimSpace(description + " Also " + code(strings.Join(v.Alternatives, "`, `")) + ".")
+		}
+		required := ""
+		if v.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n", code(v.Name), escape(v.Type), code(v.Default), required, description)
+	}
+}
+
+func writeUsageDocs(b *bytes.Buffer, vars []EnvVar) {
+	b.WriteString("This application is configured via the environment. The following environment\n")
+	b.WriteString("variables can be used:\n\n")
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 4, 4, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tDEFAULT\tREQUIRED\tDESCRIPTION")
+	for _, v := range vars {
+		required := ""
+		if v.Required {
+			required = "true"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Name, v.Type, v.Default, required, strings.Replace(v.Description, "\n", " ", -1))
+	}
+	_ = w.Flush()
+
+	// padding of empty trailing columns is trimmed
+	for _, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		b.WriteString(strings.TrimRight(line, " "))
+		b.WriteString("\n")
+	}
+}
+
+// writeEnvExample writes ENV with defaults, description, type and required flag are comments.
+// ENV of elements are commented out, as their names are patterns
+func writeEnvExample(b *bytes.Buffer, vars []EnvVar) {
+	for i, v := range vars {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		details := v.Type
+		if v.Required {
+			details += ", required"
+		}
+		if v.Description != "" {
+			fmt.Fprintf(b, "# %s (%s)\n", strings.Replace(v.Description, "\n", " ", -1), details)
+		} else {
+			fmt.Fprintf(b, "# %s\n", details)
+		}
+
+		value := v.Default
+		if v.Secret {
+			value = ""
+		}
+		if strings.Contains(v.Name, sliceIndexPattern) || strings.Contains(v.Name, mapKeyPattern) {
+			b.WriteString("# ")
+		}
+		fmt.Fprintf(b, "%s=%s\n", v.Name, value)
+	}
+}
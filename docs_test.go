@@ -0,0 +1,143 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `envconfig:"POSTGRES_HOST" desc:"Host of replica"`
+		Port int    `default:"5432"`
+		TLS  struct {
+			CAFile string `usage:"CA | bundle"`
+		}
+	}
+
+	type Config struct {
+		Version  string `envconfig:"VERSION" default:"0" desc:"Version of application"`
+		Postgres struct {
+			Host     string    `envconfig:"POSTGRES_HOST,DB_HOST" default:"localhost" required:"true" desc:"Postgres host"`
+			Password Secret    `envconfig:"POSTGRES_PASSWORD" default:"12345" required:"true" validate:"min=5"`
+			Replicas []Replica `envprefix:"REPLICAS"`
+		}
+		Redis struct {
+			Addrs []string `envconfig:"REDIS_ADDRS" sep:";"`
+		} `envconfig:"REDIS"`
+		Pools   map[string]Replica `envprefix:"POOLS"`
+		Timeout Duration           `envconfig:"TIMEOUT" default:"2s"`
+		Ignored string
+	}
+
+	tests := []struct {
+		format Format
+		expect string
+	}{
+		{
+			format: FormatMarkdown,
+			expect: "| ENV | Type | Default | Required | Description |\n" +
+				"|-----|------|---------|----------|-------------|\n" +
+				"| `VERSION` | string | `0` |  | Version of application |\n" +
+				"| `POSTGRES_HOST` | string | `localhost` | yes | Postgres host. Also `DB_HOST`. |\n" +
+				"| `POSTGRES_PASSWORD` | string | `[REDACTED]` | yes |  |\n" +
+				"| `REPLICAS_{N}_POSTGRES_HOST` | string |  |  | Host of replica. Also `REPLICAS_{N}_HOST`. |\n" +
+				"| `REPLICAS_{N}_PORT` | int | `5432` |  |  |\n" +
+				"| `REPLICAS_{N}_TLS_CA_FILE` | string |  |  | CA \\| bundle |\n" +
+				"| `REDIS` | JSON |  |  |  |\n" +
+				"| `REDIS_ADDRS` | list separated by \";\" |  |  |  |\n" +
+				"| `POOLS_{KEY}_POSTGRES_HOST` | string |  |  | Host of replica. Also `POOLS_{KEY}_HOST`. |\n" +
+				"| `POOLS_{KEY}_PORT` | int | `5432` |  |  |\n" +
+				"| `POOLS_{KEY}_TLS_CA_FILE` | string |  |  | CA \\| bundle |\n" +
+				"| `TIMEOUT` | duration | `2s` |  |  |\n",
+		},
+		{
+			format: FormatUsage,
+			expect: `This application is configured via the environment. The following environment
+variables can be used:
+
+KEY                           TYPE                     DEFAULT       REQUIRED    DESCRIPTION
+VERSION                       string                   0                         Version of application
+POSTGRES_HOST                 string                   localhost     true        Postgres host
+POSTGRES_PASSWORD             string                   [REDACTED]    true
+REPLICAS_{N}_POSTGRES_HOST    string                                             Host of replica
+REPLICAS_{N}_PORT             int                      5432
+REPLICAS_{N}_TLS_CA_FILE      string                                             CA | bundle
+REDIS                         JSON
+REDIS_ADDRS                   list separated by ";"
+POOLS_{KEY}_POSTGRES_HOST     string                                             Host of replica
+POOLS_{KEY}_PORT              int                      5432
+POOLS_{KEY}_TLS_CA_FILE       string                                             CA | bundle
+TIMEOUT                       duration                 2s
+`,
+		},
+		{
+			format: FormatEnv,
+			expect: `# Version of application (string)
+VERSION=0
+
+# Postgres host (string, required)
+POSTGRES_HOST=localhost
+
+# string, required
+POSTGRES_PASSWORD=
+
+# Host of replica (string)
+# REPLICAS_{N}_POSTGRES_HOST=
+
+# int
+# REPLICAS_{N}_PORT=5432
+
+# CA | bundle (string)
+# REPLICAS_{N}_TLS_CA_FILE=
+
+# JSON
+REDIS=
+
+# list separated by ";"
+REDIS_ADDRS=
+
+# Host of replica (string)
+# POOLS_{KEY}_POSTGRES_HOST=
+
+# int
+# POOLS_{KEY}_PORT=5432
+
+# CA | bundle (string)
+# POOLS_{KEY}_TLS_CA_FILE=
+
+# duration
+TIMEOUT=2s
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			b, err := Docs(&Config{}, tt.format)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, string(b))
+		})
+	}
+
+	t.Run("env vars", func(t *testing.T) {
+		vars := EnvVars(Config{})
+		if assert.Len(t, vars, 12) {
+			assert.Equal(t, EnvVar{
+				Name:         "REPLICAS_{N}_POSTGRES_HOST",
+				Alternatives: []string{"REPLICAS_{N}_HOST"},
+				Path:         "Postgres.Replicas[{N}].Host",
+				Type:         "string",
+				Description:  "Host of replica",
+			}, vars[3])
+			assert.True(t, vars[2].Secret)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := Docs(Config{}, FormatYAML)
+		assert.EqualError(t, err, `unsupported format of docs "yaml"`)
+	})
+}
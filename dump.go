@@ -0,0 +1,400 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format is format of dump of config
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatEnv  Format = "env"
+)
+
+// DumpOption configures Dump
+type DumpOption func(*dumper)
+
+// WithSources annotates every value of dump with its source recorded by `WithProvenance` option
+func WithSources(p *Provenance) DumpOption {
+	return func(d *dumper) {
+		d.provenance = p
+	}
+}
+
+type dumper struct {
+	provenance *Provenance
+}
+
+// dumpNode is a field of config, leaf holds value, struct, slice and map hold nested nodes
+type dumpNode struct {
+	key      string
+	path     string
+	value    interface{}
+	leaf     bool
+	array    bool
+	children []*dumpNode
+}
+
+// Dump serialises resolved config to JSON, YAML or env-file format, values of secrets are redacted.
+// JSON and YAML are keyed by `json` names, env-file has first name of `envconfig` tag of every field,
+// elements of `envprefix` slices and maps are listed by their ENV, fields without ENV are skipped
+func Dump(config interface{}, format Format, opts ...DumpOption) ([]byte, error) {
+	v := indirectWalk(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config should be a struct or a pointer to struct, got %T", config)
+	}
+
+	d := &dumper{}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	var b bytes.Buffer
+	switch format {
+	case FormatJSON:
+		if err := d.writeJSON(&b, d.node("", "", reflect.StructField{}, v, false), ""); err != nil {
+			return nil, err
+		}
+		b.WriteString("\n")
+	case FormatYAML:
+		lines, err := d.yamlLines(d.node("", "", reflect.StructField{}, v, false))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	case FormatEnv:
+		if err := d.writeEnv(&b, "", "", false, false, v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format of dump %q", format)
+	}
+	return b.Bytes(), nil
+}
+
+// node builds tree of value `v` of field `t` stored under `path` of field names, fields are keyed the same way
+// as config file is decoded. Value nested in field tagged as secret is `secret` as well
+func (d *dumper) node(key, path string, t reflect.StructField, v reflect.Value, secret bool) *dumpNode {
+	n := &dumpNode{key: key, path: path}
+	if isLeafType(v.Type()) || !indirectWalk(v).IsValid() || indirectWalk(v).Kind() == reflect.Ptr {
+		n.leaf, n.value = true, dumpValue(t, v, secret)
+		return n
+	}
+
+	v = indirectWalk(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range jsonFields(v.Type()) {
+			if field, ok := fieldByIndex(v, f.Index); ok {
+				n.children = append(n.children, d.node(jsonName(f), joinPath(path, f.Name), f, field, secret || isSecretTag(f)))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		n.array = true
+		for i := 0; i < v.Len(); i++ {
+			n.children = append(n.children, d.node("", fmt.Sprintf("%s[%d]", path, i), t, v.Index(i), secret))
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			n.children = append(n.children, d.node(fmt.Sprint(key), fmt.Sprintf("%s[%v]", path, key), t, v.MapIndex(key), secret))
+		}
+	}
+	return n
+}
+
+// fieldByIndex returns field of struct `v` by index of promoted field, it is not found, when embedded pointer is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			if v = indirectWalk(v); v.Kind() != reflect.Struct {
+				return reflect.Value{}, false
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// source returns source of value stored under `path`, it is empty, when sources are not annotated
+func (d *dumper) source(path string) string {
+	if d.provenance == nil {
+		return ""
+	}
+	origin, ok := d.provenance.Explain(path)
+	if !ok {
+		return ""
+	}
+	return origin.source()
+}
+
+// writeJSON writes node indented by `indent`, annotated value is written as object with value and source
+func (d *dumper) writeJSON(b *bytes.Buffer, n *dumpNode, indent string) error {
+	if n.leaf {
+		value, err := marshalJSON(n.value)
+		if err != nil {
+			return fmt.Errorf("dump %s: %s", n.path, err)
+		}
+		if source := d.source(n.path); source != "" {
+			quoted, err := marshalJSON(source)
+			if err != nil {
+				return fmt.Errorf("dump %s: %s", n.path, err)
+			}
+			value = []byte(fmt.Sprintf(`{"value": %s, "source": %s}`, value, quoted))
+		}
+		b.Write(value)
+		return nil
+	}
+
+	begin, end := "{", "}"
+	if n.array {
+		begin, end = "[", "]"
+	}
+	b.WriteString(begin)
+	for i, child := range n.children {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n" + indent + "  ")
+		if !n.array {
+			// key is quoted as JSON string, Go quoting differs for control characters
+			key, err := marshalJSON(child.key)
+			if err != nil {
+				return fmt.Errorf("dump %s: %s", child.path, err)
+			}
+			b.Write(key)
+			b.WriteString(": ")
+		}
+		if err := d.writeJSON(b, child, indent+"  "); err != nil {
+			return err
+		}
+	}
+	if len(n.children) > 0 {
+		b.WriteString("\n" + indent)
+	}
+	b.WriteString(end)
+	return nil
+}
+
+// yamlLines returns lines of nested nodes, annotated value is followed by comment with source
+func (d *dumper) yamlLines(n *dumpNode) ([]string, error) {
+	var lines []string
+	for _, child := range n.children {
+		prefix := "- "
+		if !n.array {
+			key, err := yamlScalar(child.key)
+			if err != nil {
+				return nil, err
+			}
+			prefix = key + ": "
+		}
+
+		if child.leaf {
+			value, err := yamlScalar(child.value)
+			if err != nil {
+				return nil, fmt.Errorf("dump %s: %s", child.path, err)
+			}
+			line := prefix + value
+			if source := d.source(child.path); source != "" {
+				line += " # " + source
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		nested, err := d.yamlLines(child)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(nested) == 0 && child.array:
+			lines = append(lines, prefix+"[]")
+		case len(nested) == 0:
+			lines = append(lines, prefix+"{}")
+		case n.array:
+			lines = append(lines, prefix+nested[0])
+			for _, line := range nested[1:] {
+				lines = append(lines, "  "+line)
+			}
+		default:
+			lines = append(lines, strings.TrimSuffix(prefix, " "))
+			for _, line := range nested {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// writeEnv writes ENV of fields of struct `v` stored under `path`. Fields of elements of `envprefix` slices
+// and maps are named after `prefix` by `envconfig` tag or field name, the same way ENV are applied.
+// Values of struct nested in field tagged as secret are `secret`
+func (d *dumper) writeEnv(b *bytes.Buffer, path, prefix string, element, secret bool, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		t, f := v.Type().Field(i), v.Field(i)
+		if t.PkgPath != "" {
+			continue
+		}
+
+		fieldPath, fieldSecret := joinPath(path, t.Name), secret || isSecretTag(t)
+
+		if value, ok := t.Tag.Lookup(envPrefixTag); ok && !isLeafType(f.Type()) && indirectType(f.Type()).Kind() != reflect.Struct {
+			if err := d.writeEnvElements(b, fieldPath, prefix+value, fieldSecret, indirectWalk(f)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isComposite(f.Type()) && indirectType(f.Type()).Kind() == reflect.Struct {
+			if f = indirectWalk(f); f.Kind() != reflect.Struct {
+				continue
+			}
+			nestedPrefix := prefix
+			if element {
+				nestedPrefix += toEnvKey(t.Name) + "_"
+			}
+			if err := d.writeEnv(b, fieldPath, nestedPrefix, element, fieldSecret, f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		names := envNames(t)
+		if element {
+			names = append(names, toEnvKey(t.Name))
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		value, err := envValue(t, dumpValue(t, f, fieldSecret))
+		if err != nil {
+			return fmt.Errorf("dump %s: %s", fieldPath, err)
+		}
+		if source := d.source(fieldPath); source != "" {
+			fmt.Fprintf(b, "# %s: %s\n", fieldPath, source)
+		}
+		fmt.Fprintf(b, "%s%s=%s\n", prefix, names[0], value)
+	}
+	return nil
+}
+
+// writeEnvElements writes ENV of elements of `envprefix` slice or map `v` stored under `path`
+func (d *dumper) writeEnvElements(b *bytes.Buffer, path, prefix string, secret bool, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if elem := indirectWalk(v.Index(i)); elem.Kind() == reflect.Struct {
+				if err := d.writeEnv(b, fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%s_%d_", prefix, i), true, secret, elem); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			if elem := indirectWalk(v.MapIndex(key)); elem.Kind() == reflect.Struct {
+				elemPrefix := prefix + "_" + mapKeyToEnv(fmt.Sprint(key)) + "_"
+				if err := d.writeEnv(b, fmt.Sprintf("%s[%v]", path, key), elemPrefix, true, secret, elem); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dumpValue returns value of field `t` for dump, durations and time are formatted, secret is redacted
+func dumpValue(t reflect.StructField, v reflect.Value, secret bool) interface{} {
+	if secret || isSecret(t, v.Type()) {
+		return redacted
+	}
+
+	v = indirectWalk(v)
+	if !v.IsValid() || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		return nil
+	}
+
+	switch v.Type() {
+	case durationType, durationCustomType:
+		return time.Duration(v.Int()).String()
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+	return v.Interface()
+}
+
+// envValue formats value of field `t` as ENV, lists are joined by separator of field, composite values are JSON
+func envValue(t reflect.StructField, value interface{}) (string, error) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case []string:
+		s = listFormatOf(t).join(v)
+	default:
+		if isComposite(reflect.TypeOf(value)) {
+			b, err := marshalJSON(value)
+			if err != nil {
+				return "", err
+			}
+			s = string(b)
+		} else {
+			s = fmt.Sprint(value)
+		}
+	}
+
+	switch {
+	case !strings.ContainsAny(s, " \t\r\n\"'#$\\`"):
+		return s, nil
+	case !strings.ContainsAny(s, "'\r\n"):
+		// single quoted value is taken literally
+		return "'" + s + "'", nil
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`, "`", "\\`").Replace(s) + `"`, nil
+}
+
+// yamlScalar formats scalar as YAML, lists and maps are written in flow style as JSON
+func yamlScalar(value interface{}) (string, error) {
+	if value == nil {
+		return "null", nil
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		b, err := marshalJSON(value)
+		return string(b), err
+	}
+	if s, ok := value.(string); ok && strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s), nil
+	}
+
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// marshalJSON marshals value without escaping of HTML
+func marshalJSON(value interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
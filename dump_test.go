@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host" envconfig:"HOST"`
+		Port int    `json:"port" default:"5432"`
+	}
+
+	var cfg struct {
+		Version  string `envconfig:"DUMP_VERSION" default:"1.0"`
+		Postgres struct {
+			Host     string    `json:"host"     envconfig:"DUMP_POSTGRES_HOST" default:"localhost"`
+			Password Secret    `json:"password" envconfig:"DUMP_POSTGRES_PASSWORD"`
+			Token    string    `json:"token"    envconfig:"DUMP_POSTGRES_TOKEN" secret:"true"`
+			Replicas []Replica `json:"replicas" envprefix:"DUMP_REPLICAS"`
+		} `json:"postgres"`
+		Redis struct {
+			Addrs []string `json:"addrs" envconfig:"DUMP_REDIS_ADDRS" default:"a:1,b:2"`
+		} `json:"redis"`
+		Pools   map[string]Replica `json:"pools"   envprefix:"DUMP_POOLS"`
+		Limits  map[string]int     `json:"limits"  envconfig:"DUMP_LIMITS"`
+		Timeout Duration           `json:"timeout" envconfig:"DUMP_TIMEOUT" default:"2s"`
+		MOTD    string             `json:"motd"    envconfig:"DUMP_MOTD"`
+		Mirrors []Replica          `json:"mirrors"`
+	}
+
+	var p Provenance
+	err := Init(&cfg, "", WithProvenance(&p), WithEnv(testEnv(
+		"DUMP_POSTGRES_PASSWORD", "s3cr3t",
+		"DUMP_POSTGRES_TOKEN", "s3cr3t",
+		"DUMP_REPLICAS_0_HOST", "replica0",
+		"DUMP_POOLS_ORDERS_HOST", "orders",
+		"DUMP_LIMITS", `{"orders": 10}`,
+		"DUMP_MOTD", `say "hi"`,
+	)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		opts   []DumpOption
+		expect string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			expect: `{
+  "Version": "1.0",
+  "postgres": {
+    "host": "localhost",
+    "password": "[REDACTED]",
+    "token": "[REDACTED]",
+    "replicas": [
+      {
+        "host": "replica0",
+        "port": 5432
+      }
+    ]
+  },
+  "redis": {
+    "addrs": ["a:1","b:2"]
+  },
+  "pools": {
+    "orders": {
+      "host": "orders",
+      "port": 5432
+    }
+  },
+  "limits": {"orders":10},
+  "timeout": "2s",
+  "motd": "say \"hi\"",
+  "mirrors": []
+}
+`,
+		},
+		{
+			name:   "json with sources",
+			format: FormatJSON,
+			opts:   []DumpOption{WithSources(&p)},
+			expect: `{
+  "Version": {"value": "1.0", "source": "default"},
+  "postgres": {
+    "host": {"value": "localhost", "source": "default"},
+    "password": {"value": "[REDACTED]", "source": "env DUMP_POSTGRES_PASSWORD"},
+    "token": {"value": "[REDACTED]", "source": "env DUMP_POSTGRES_TOKEN"},
+    "replicas": [
+      {
+        "host": {"value": "replica0", "source": "env DUMP_REPLICAS_0_HOST"},
+        "port": {"value": 5432, "source": "default"}
+      }
+    ]
+  },
+  "redis": {
+    "addrs": {"value": ["a:1","b:2"], "source": "default"}
+  },
+  "pools": {
+    "orders": {
+      "host": {"value": "orders", "source": "env DUMP_POOLS_ORDERS_HOST"},
+      "port": {"value": 5432, "source": "default"}
+    }
+  },
+  "limits": {"value": {"orders":10}, "source": "env DUMP_LIMITS"},
+  "timeout": {"value": "2s", "source": "default"},
+  "motd": {"value": "say \"hi\"", "source": "env DUMP_MOTD"},
+  "mirrors": []
+}
+`,
+		},
+		{
+			name:   "yaml with sources",
+			format: FormatYAML,
+			opts:   []DumpOption{WithSources(&p)},
+			expect: `Version: "1.0" # default
+postgres:
+  host: localhost # default
+  password: '[REDACTED]' # env DUMP_POSTGRES_PASSWORD
+  token: '[REDACTED]' # env DUMP_POSTGRES_TOKEN
+  replicas:
+    - host: replica0 # env DUMP_REPLICAS_0_HOST
+      port: 5432 # default
+redis:
+  addrs: ["a:1","b:2"] # default
+pools:
+  orders:
+    host: orders # env DUMP_POOLS_ORDERS_HOST
+    port: 5432 # default
+limits: {"orders":10} # env DUMP_LIMITS
+timeout: 2s # default
+motd: say "hi" # env DUMP_MOTD
+mirrors: []
+`,
+		},
+		{
+			name:   "env",
+			format: FormatEnv,
+			expect: `DUMP_VERSION=1.0
+DUMP_POSTGRES_HOST=localhost
+DUMP_POSTGRES_PASSWORD=[REDACTED]
+DUMP_POSTGRES_TOKEN=[REDACTED]
+DUMP_REPLICAS_0_HOST=replica0
+DUMP_REPLICAS_0_PORT=5432
+DUMP_REDIS_ADDRS=a:1,b:2
+DUMP_POOLS_ORDERS_HOST=orders
+DUMP_POOLS_ORDERS_PORT=5432
+DUMP_LIMITS='{"orders":10}'
+DUMP_TIMEOUT=2s
+DUMP_MOTD='say "hi"'
+`,
+		},
+		{
+			name:   "env with sources",
+			format: FormatEnv,
+			opts:   []DumpOption{WithSources(&p)},
+			expect: `# Version: default
+DUMP_VERSION=1.0
+# Postgres.Host: default
+DUMP_POSTGRES_HOST=localhost
+# Postgres.Password: env DUMP_POSTGRES_PASSWORD
+DUMP_POSTGRES_PASSWORD=[REDACTED]
+# Postgres.Token: env DUMP_POSTGRES_TOKEN
+DUMP_POSTGRES_TOKEN=[REDACTED]
+# Postgres.Replicas[0].Host: env DUMP_REPLICAS_0_HOST
+DUMP_REPLICAS_0_HOST=replica0
+# Postgres.Replicas[0].Port: default
+DUMP_REPLICAS_0_PORT=5432
+# Redis.Addrs: default
+DUMP_REDIS_ADDRS=a:1,b:2
+# Pools[orders].Host: env DUMP_POOLS_ORDERS_HOST
+DUMP_POOLS_ORDERS_HOST=orders
+# Pools[orders].Port: default
+DUMP_POOLS_ORDERS_PORT=5432
+# Limits: env DUMP_LIMITS
+DUMP_LIMITS='{"orders":10}'
+# Timeout: default
+DUMP_TIMEOUT=2s
+# MOTD: env DUMP_MOTD
+DUMP_MOTD='say "hi"'
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Dump(&cfg, tt.format, tt.opts...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, string(b))
+		})
+	}
+
+	t.Run("fields nested in secret, hidden and embedded fields", func(t *testing.T) {
+		type Base struct {
+			Host string `json:"host"`
+		}
+		type Admin struct {
+			User string
+			Pass string
+		}
+
+		var cfg struct {
+			Base
+			Creds struct {
+				User string `json:"user" envconfig:"DUMP_CREDS_USER"`
+				Pass string `json:"pass" envconfig:"DUMP_CREDS_PASS"`
+			} `json:"creds" secret:"true"`
+			Admins []Admin `json:"admins" envprefix:"DUMP_ADMINS" secret:"true"`
+			Hidden string  `json:"-"      envconfig:"DUMP_HIDDEN"`
+		}
+		cfg.Host = "localhost"
+		cfg.Creds.User, cfg.Creds.Pass = "admin", "s3cr3t"
+		cfg.Admins = []Admin{{User: "root", Pass: "s3cr3t"}}
+		cfg.Hidden = "internal"
+
+		b, err := Dump(&cfg, FormatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "host": "localhost",
+  "creds": {
+    "user": "[REDACTED]",
+    "pass": "[REDACTED]"
+  },
+  "admins": [
+    {
+      "User": "[REDACTED]",
+      "Pass": "[REDACTED]"
+    }
+  ]
+}
+`, string(b))
+
+		b, err = Dump(&cfg, FormatYAML)
+		assert.NoError(t, err)
+		assert.Equal(t, `host: localhost
+creds:
+  user: '[REDACTED]'
+  pass: '[REDACTED]'
+admins:
+  - User: '[REDACTED]'
+    Pass: '[REDACTED]'
+`, string(b))
+
+		b, err = Dump(&cfg, FormatEnv)
+		assert.NoError(t, err)
+		assert.Equal(t, `DUMP_CREDS_USER=[REDACTED]
+DUMP_CREDS_PASS=[REDACTED]
+DUMP_ADMINS_0_USER=[REDACTED]
+DUMP_ADMINS_0_PASS=[REDACTED]
+DUMP_HIDDEN=internal
+`, string(b))
+	})
+
+	t.Run("keys and sources with control characters", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "dump")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "config\x7f.json")
+		if err := ioutil.WriteFile(filename, []byte(`{"labels": {" k\u007f": {"name": "v"}}}`), 0600); !assert.NoError(t, err) {
+			return
+		}
+
+		var (
+			cfg struct {
+				Labels map[string]struct {
+					Name string `json:"name"`
+				} `json:"labels"`
+			}
+			p Provenance
+		)
+		if err := Init(&cfg, filename, WithProvenance(&p), WithEnv(MapEnv{})); !assert.NoError(t, err) {
+			return
+		}
+
+		b, err := Dump(&cfg, FormatJSON, WithSources(&p))
+		assert.NoError(t, err)
+		assert.True(t, json.Valid(b), string(b))
+
+		var dumped struct {
+			Labels map[string]struct {
+				Name struct {
+					Value  string `json:"value"`
+					Source string `json:"source"`
+				} `json:"name"`
+			} `json:"labels"`
+		}
+		assert.NoError(t, json.Unmarshal(b, &dumped))
+		assert.Equal(t, "v", dumped.Labels[" k\x7f"].Name.Value)
+		assert.Contains(t, dumped.Labels[" k\x7f"].Name.Source, filename)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := Dump(&cfg, "toml")
+		assert.EqualError(t, err, `unsupported format of dump "toml"`)
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := Dump("config", FormatJSON)
+		assert.EqualError(t, err, "config should be a struct or a pointer to struct, got string")
+	})
+}
+
+func TestEnvValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value  interface{}
+		tag    string
+		expect string
+	}{
+		{value: "plain", expect: "plain"},
+		{value: nil, expect: ""},
+		{value: 10, expect: "10"},
+		{value: "it's $HOME", expect: `"it's \$HOME"`},
+		{value: "line\nbreak", expect: `"line\nbreak"`},
+		{value: []string{`a,b`, `say "hi"`, `c`}, expect: `'a\,b,say \"hi\",c'`},
+		{value: []string{"a", "b"}, tag: `sep:";"`, expect: "a;b"},
+	}
+
+	for _, tt := range tests {
+		value, err := envValue(reflect.StructField{Tag: reflect.StructTag(tt.tag)}, tt.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expect, value)
+	}
+	assert.Equal(t, []string{`a,b`, `say "hi"`, `c`}, listFormat{sep: ","}.split(`a\,b,say \"hi\",c`))
+}
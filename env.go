@@ -1,10 +1,64 @@
 package config
 
-import "os"
+import (
+	"os"
+	"sort"
+	"strings"
+)
 
-var lookupEnv = os.LookupEnv
-var environ = os.Environ
+// Env is a source of environment variables
+type Env interface {
+	// LookupEnv retrieves the value of the environment variable named by the key
+	LookupEnv(key string) (string, bool)
+	// Environ returns a copy of strings representing the environment, in the form "key=value"
+	Environ() []string
+}
+
+// OSEnv is environment of current process
+type OSEnv struct{}
+
+// LookupEnv implements Env
+func (OSEnv) LookupEnv(key string) (string, bool) { return os.LookupEnv(key) }
+
+// Environ implements Env
+func (OSEnv) Environ() []string { return os.Environ() }
+
+// MapEnv is environment stored in map, e.g. captured snapshot or test fixture
+type MapEnv map[string]string
+
+// LookupEnv implements Env
+func (e MapEnv) LookupEnv(key string) (string, bool) {
+	value, ok := e[key]
+	return value, ok
+}
+
+// Environ implements Env, variables are sorted by key
+func (e MapEnv) Environ() []string {
+	environ := make([]string, 0, len(e))
+	for key, value := range e {
+		environ = append(environ, key+"="+value)
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// SnapshotEnv captures environment of current process
+func SnapshotEnv() MapEnv {
+	env := make(MapEnv)
+	for _, keyVal := range os.Environ() {
+		if i := strings.Index(keyVal, "="); i > 0 {
+			env[keyVal[:i]] = keyVal[i+1:]
+		}
+	}
+	return env
+}
+
+// Setenv sets the value of the environment variable of current process.
+//
+// Deprecated: pass environment to Init by WithEnv instead
 var Setenv = os.Setenv
-var Unsetenv = os.Unsetenv
 
-//var ExpandEnv = os.ExpandEnv
+// Unsetenv unsets the environment variable of current process.
+//
+// Deprecated: pass environment to Init by WithEnv instead
+var Unsetenv = os.Unsetenv
@@ -1,19 +1,46 @@
 package config
 
-type envs []string
+import (
+	"os"
+	"testing"
 
-func (e envs) set(args ...string) envs {
-	if len(args)%2 == 0 {
-		for i := 0; i < len(args); i = i + 2 {
-			e = append(e, args[i])
-			_ = Setenv(args[i], args[i+1])
+	"github.com/stretchr/testify/assert"
+)
+
+// testEnv builds environment from key value pairs
+func testEnv(pairs ...string) MapEnv {
+	env := make(MapEnv, len(pairs)/2)
+	if len(pairs)%2 == 0 {
+		for i := 0; i < len(pairs); i = i + 2 {
+			env[pairs[i]] = pairs[i+1]
 		}
 	}
-	return e
+	return env
+}
+
+func TestMapEnv(t *testing.T) {
+	t.Parallel()
+
+	env := MapEnv{"B": "2", "A": "1=1"}
+
+	value, ok := env.LookupEnv("A")
+	assert.True(t, ok)
+	assert.Equal(t, "1=1", value)
+
+	_, ok = env.LookupEnv("C")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"A=1=1", "B=2"}, env.Environ())
 }
 
-func (e envs) unset() {
-	for _, key := range e {
-		_ = Unsetenv(key)
+func TestSnapshotEnv(t *testing.T) {
+	t.Parallel()
+
+	env := SnapshotEnv()
+	for _, key := range []string{"PATH", "HOME"} {
+		expect, ok := os.LookupEnv(key)
+		value, found := env.LookupEnv(key)
+		assert.Equal(t, ok, found, key)
+		assert.Equal(t, expect, value, key)
 	}
 }
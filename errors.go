@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// Source is a step of loading, which produced field error
+type Source string
+
+const (
+	SourceDefault  Source = "default"
+	SourceFile     Source = "file"
+	SourceSecret   Source = "secret"
+	SourceEnv      Source = "env"
+	SourceValidate Source = "validate"
+)
+
+var (
+	// ErrRequired is reported for `required` field, which is not filled up
+	ErrRequired = errors.New("field is not filled up")
+	// ErrInvalid is reported for field, which does not satisfy rule of `validate` tag
+	ErrInvalid = errors.New("invalid value")
+)
+
+// FieldError describes failure of field stored under Path, e.g. `Postgres.Replicas[0].Port`.
+// Key is ENV name, secret file or rule of `validate` tag, Value is raw value, which failed
+type FieldError struct {
+	Path   string
+	Source Source
+	Key    string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	if e.Source != "" {
+		b.WriteString(string(e.Source))
+		if e.Key != "" {
+			b.WriteString(" ")
+			b.WriteString(e.Key)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError aggregates errors of all failed fields, so every problem of configuration is reported at once
+type MultiError []error
+
+func (m MultiError) Error() string {
+	messages := make([]string, 0, len(m))
+	for _, err := range m {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns aggregated errors
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// Is reports whether any of aggregated errors matches target
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of aggregated errors, which matches target
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// append appends error, aggregated errors are flattened
+func (m MultiError) append(err error) MultiError {
+	if err == nil {
+		return m
+	}
+	if errs, ok := err.(MultiError); ok {
+		return append(m, errs...)
+	}
+	return append(m, err)
+}
+
+// err returns nil, when there are no errors
+func (m MultiError) err() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
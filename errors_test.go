@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitErrors(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `envconfig:"HOST" required:"true"`
+		Port int    `envconfig:"PORT" default:"5432"`
+	}
+
+	type Config struct {
+		Postgres struct {
+			Host     string    `envconfig:"ERRORS_POSTGRES_HOST" required:"true"`
+			Port     int       `envconfig:"ERRORS_POSTGRES_PORT" validate:"min=1"`
+			Replicas []Replica `envprefix:"ERRORS_REPLICAS"`
+		}
+		Retries int `default:"many"`
+	}
+
+	var cfg Config
+	err := Init(&cfg, "", WithEnv(testEnv(
+		"ERRORS_POSTGRES_PORT", "port",
+		"ERRORS_REPLICAS_0_HOST", "replica",
+		"ERRORS_REPLICAS_0_PORT", "port",
+		"ERRORS_REPLICAS_1_PORT", "5433",
+	)))
+
+	var errs MultiError
+	if !assert.True(t, errors.As(err, &errs)) {
+		return
+	}
+
+	assert.Equal(t, MultiError{
+		&FieldError{Path: "Retries", Source: SourceDefault, Value: "many", Err: errors.New(`failed to parse value "many" as Int64 type`)},
+		&FieldError{Path: "Postgres.Port", Source: SourceEnv, Key: "ERRORS_POSTGRES_PORT", Value: "port",
+			Err: errors.New(`failed to parse value "port" as Int64 type`)},
+		&FieldError{Path: "Postgres.Replicas[0].Port", Source: SourceEnv, Key: "ERRORS_REPLICAS_0_PORT", Value: "port",
+			Err: errors.New(`failed to parse value "port" as Int64 type`)},
+		&FieldError{Path: "Postgres.Host", Source: SourceValidate, Key: "required", Err: ErrRequired},
+		&FieldError{Path: "Postgres.Port", Source: SourceValidate, Key: "min=1", Value: "0", Err: fmt.Errorf("%w 0", ErrInvalid)},
+		&FieldError{Path: "Postgres.Replicas[1].Host", Source: SourceValidate, Key: "required", Err: ErrRequired},
+	}, errs)
+
+	assert.True(t, errors.Is(err, ErrRequired))
+	assert.True(t, errors.Is(err, ErrInvalid))
+
+	var fieldErr *FieldError
+	if assert.True(t, errors.As(err, &fieldErr)) {
+		assert.Equal(t, "Retries", fieldErr.Path)
+	}
+
+	assert.EqualError(t, errs[4], "Postgres.Port: validate min=1: invalid value 0")
+}
+
+func TestMultiError(t *testing.T) {
+	t.Parallel()
+
+	var errs MultiError
+	assert.NoError(t, errs.err())
+
+	errs = errs.append(nil)
+	errs = errs.append(ErrNotStruct)
+	errs = errs.append(MultiError{ErrNotPointer, &FieldError{Path: "Host", Err: ErrRequired}})
+
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs, "should be a structure; should be a pointer; Host: field is not filled up")
+	assert.True(t, errors.Is(errs, ErrNotPointer))
+	assert.False(t, errors.Is(errs, ErrInvalid))
+}
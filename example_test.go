@@ -7,15 +7,15 @@ import (
 	"time"
 )
 
-func init() {
-	// setup ENV
-	_ = config.Setenv("VERSION", "0.0.1")
-	_ = config.Setenv("REPLICAS_0_POSTGRES_USER", "replica0")
-	_ = config.Setenv("REPLICAS_0_POSTGRES_PORT", "5433")
-	_ = config.Setenv("REPLICAS_1_USER", "replica1")
-	_ = config.Setenv("REPLICAS_1_PORT", "5433")
-	_ = config.Setenv("REDIS_ADDR", "127.0.0.1:6377,127.0.0.1:6378,127.0.0.1:6379")
-	_ = config.Setenv("READ_TIMEOUT", "30s")
+// env is used instead of environment of current process
+var env = config.MapEnv{
+	"VERSION":                  "0.0.1",
+	"REPLICAS_0_POSTGRES_USER": "replica0",
+	"REPLICAS_0_POSTGRES_PORT": "5433",
+	"REPLICAS_1_USER":          "replica1",
+	"REPLICAS_1_PORT":          "5433",
+	"REDIS_ADDR":               "127.0.0.1:6377,127.0.0.1:6378,127.0.0.1:6379",
+	"READ_TIMEOUT":             "30s",
 }
 
 func ExampleInit() {
@@ -24,10 +24,10 @@ func ExampleInit() {
 	}
 
 	type Postgres struct {
-		Host     string `json:"host"     envconfig:"POSTGRES_HOST"     default:"localhost"`
-		Port     string `json:"port"     envconfig:"POSTGRES_PORT"     default:"5432"`
-		User     string `json:"user"     envconfig:"POSTGRES_USER"     default:"postgres"`
-		Password string `json:"password" envconfig:"POSTGRES_PASSWORD" default:"12345"`
+		Host     string        `json:"host"     envconfig:"POSTGRES_HOST"     default:"localhost"`
+		Port     string        `json:"port"     envconfig:"POSTGRES_PORT"     default:"5432"`
+		User     string        `json:"user"     envconfig:"POSTGRES_USER"     default:"postgres"`
+		Password config.Secret `json:"password" envconfig:"POSTGRES_PASSWORD" default:"12345"`
 	}
 
 	type Redis struct {
@@ -54,21 +54,21 @@ func ExampleInit() {
 		Websocket Websocket  `json:"websocket"`
 	}
 
-	if err := config.Init(&cfg, "testdata/config.json"); err != nil {
+	if err := config.Init(&cfg, "testdata/config.json", config.WithEnv(env)); err != nil {
 		log.Fatalln(err)
 	}
 
 	fmt.Println(cfg)
-	// Output: {0.0.1 {localhost:8080} {localhost 5432 postgres 12345} [{localhost 5433 replica0 12345} {localhost 5433 replica1 12345}] {[127.0.0.1:6377 127.0.0.1:6378 127.0.0.1:6379]} {nats://localhost:4222 5 2000000000} {9876}}
+	// Output: {0.0.1 {localhost:8080} {localhost 5432 postgres [REDACTED]} [{localhost 5433 replica0 [REDACTED]} {localhost 5433 replica1 [REDACTED]}] {[127.0.0.1:6377 127.0.0.1:6378 127.0.0.1:6379]} {nats://localhost:4222 5 2000000000} {9876}}
 }
 
-func ExampleInitTimeout() {
+func ExampleInit_timeout() {
 	var cfg struct {
 		ReadTimeout  time.Duration `envconfig:"READ_TIMEOUT"  default:"1s"`
 		WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"10s"`
 	}
 
-	if err := config.Init(&cfg, ""); err != nil {
+	if err := config.Init(&cfg, "", config.WithEnv(env)); err != nil {
 		log.Fatalln(err)
 	}
 
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// expander expands `${VAR}`, `${VAR:-fallback}` and `${path.to.field}` references in string values.
+// Names containing dot are paths of config fields by `json` names, e.g. `${postgres.host}` or `${.version}`,
+// other names are ENV. `$${` is kept as literal `${`
+type expander struct {
+	l        *loader
+	root     reflect.Value
+	resolved map[string]string
+	visiting []string
+}
+
+func (l *loader) newExpander(root reflect.Value) *expander {
+	return &expander{l: l, root: root, resolved: make(map[string]string)}
+}
+
+// expandValues expands string values of `v` stored under `path`
+func (e *expander) expandValues(path string, v reflect.Value) error {
+	return walkStrings(path, v, func(path string, v reflect.Value) error {
+		_, err := e.expandField(path, v)
+		return err
+	})
+}
+
+// forget drops expanded values of field stored under `path` and its nested fields, once field is set again
+func (e *expander) forget(path string) {
+	for p := range e.resolved {
+		if p == path || strings.HasPrefix(p, path+".") {
+			delete(e.resolved, p)
+		}
+	}
+}
+
+// resolve returns expanded value of config field at `path`
+func (e *expander) resolve(path string) (string, error) {
+	f, canonical, ok := fieldByPath(e.root, path)
+	if !ok {
+		return "", fmt.Errorf("config field %q referenced by ${%s} not found", path, path)
+	}
+	return e.expandField(canonical, f)
+}
+
+// expandField expands value of field `f` stored under `path` and returns it
+func (e *expander) expandField(path string, f reflect.Value) (string, error) {
+	f = indirectWalk(f)
+	if f.Kind() != reflect.String {
+		return fmt.Sprint(f.Interface()), nil
+	}
+
+	value, ok := e.resolved[path]
+	if !ok {
+		for i, p := range e.visiting {
+			if p == path {
+				return "", fmt.Errorf("cycle in config references: %s -> %s", strings.Join(e.visiting[i:], " -> "), path)
+			}
+		}
+
+		e.visiting = append(e.visiting, path)
+		expanded, err := e.expand(f.String())
+		e.visiting = e.visiting[:len(e.visiting)-1]
+		if err != nil {
+			if len(e.visiting) > 0 {
+				return "", err
+			}
+			if isSecretJSONPath(e.root.Type(), path) {
+				err = redactError(err, f.String())
+			}
+			return "", fmt.Errorf("expand %s: %s", path, err)
+		}
+
+		value = expanded
+		e.resolved[path] = value
+	}
+
+	// values of maps are copies, so they are set once walked
+	if f.CanSet() {
+		f.SetString(value)
+	}
+	return value, nil
+}
+
+// expand expands all references in `value`
+func (e *expander) expand(value string) (string, error) {
+	return expandString(value, func(name string) (string, bool, error) {
+		if !strings.Contains(name, ".") {
+			value, found := e.l.lookupEnv(name)
+			return value, found, nil
+		}
+		value, err := e.resolve(strings.TrimPrefix(name, "."))
+		return value, err == nil, err
+	})
+}
+
+// expandEnv expands ENV references only, it is used for values which are parsed before config is built
+func (l *loader) expandEnv(value string) (string, error) {
+	return expandString(value, func(name string) (string, bool, error) {
+		if strings.Contains(name, ".") {
+			return "", false, fmt.Errorf("config field reference ${%s} is allowed in string values only", name)
+		}
+		value, found := l.lookupEnv(name)
+		return value, found, nil
+	})
+}
+
+func expandString(value string, lookup func(name string) (string, bool, error)) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if strings.HasPrefix(value[i:], "$${") {
+			b.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		end := closingBrace(value, i+2)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed reference in %q", value)
+		}
+
+		name, fallback, hasFallback := value[i+2:end], "", false
+		if n := strings.Index(name, ":-"); n >= 0 {
+			name, fallback, hasFallback = name[:n], name[n+2:], true
+		}
+
+		v, found, err := lookup(name)
+		if err != nil {
+			return "", err
+		}
+		if hasFallback && (!found || v == "") {
+			if v, err = expandString(fallback, lookup); err != nil {
+				return "", err
+			}
+		}
+
+		b.WriteString(v)
+		i = end
+	}
+
+	return b.String(), nil
+}
+
+// closingBrace returns index of brace, which closes reference started at `start`
+func closingBrace(value string, start int) int {
+	depth := 1
+	for i := start; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "${"):
+			depth++
+			i++
+		case value[i] == '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// walkStrings calls `fn` for every string value of `v` with its path of `json` names
+func walkStrings(path string, v reflect.Value, fn func(path string, v reflect.Value) error) error {
+	v = indirectWalk(v)
+
+	switch v.Kind() {
+	case reflect.String:
+		return fn(path, v)
+	case reflect.Struct:
+		if isTime(v.Type()) {
+			return nil
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if err := walkStrings(joinPath(path, jsonName(f)), v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(joinPath(path, strconv.Itoa(i)), v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			if err := walkStrings(joinPath(path, key.String()), value, fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	}
+
+	return nil
+}
+
+// fieldByPath looks up value by path of `json` names or field names, returns path of `json` names as well
+func fieldByPath(v reflect.Value, path string) (reflect.Value, string, bool) {
+	if path == "" {
+		return v, "", true
+	}
+
+	name, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+
+	v = indirectWalk(v)
+
+	var (
+		next      reflect.Value
+		canonical = name
+	)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath == "" && (jsonName(f) == name || strings.EqualFold(f.Name, name)) {
+				next, canonical = v.Field(i), jsonName(f)
+				break
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < v.Len() {
+			next = v.Index(i)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			next = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		}
+	}
+
+	if !next.IsValid() {
+		return reflect.Value{}, "", false
+	}
+
+	value, rest, ok := fieldByPath(next, rest)
+	return value, joinPath(canonical, rest), ok
+}
+
+// jsonPathOf converts path of field names of type `t`, e.g. `Replicas[0].Host`, to path of `json` names,
+// e.g. `replicas.0.host`, which expander refers fields by
+func jsonPathOf(t reflect.Type, path string) string {
+	var names []string
+	for path != "" {
+		t = indirectType(t)
+
+		if strings.HasPrefix(path, "[") {
+			end := strings.Index(path, "]")
+			if end < 0 {
+				break
+			}
+			names = append(names, path[1:end])
+			path = strings.TrimPrefix(path[end+1:], ".")
+			if k := t.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+				t = t.Elem()
+			}
+			continue
+		}
+
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		name := path[:end]
+		path = strings.TrimPrefix(path[end:], ".")
+
+		if t.Kind() == reflect.Struct {
+			if f, ok := t.FieldByName(name); ok {
+				name, t = jsonName(f), f.Type
+			}
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ".")
+}
+
+// jsonName returns name of field in config file
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" || name == "" {
+		return path + name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandString(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"HOST": "localhost", "EMPTY": ""}
+	lookup := func(name string) (string, bool, error) {
+		if name == "ERROR" {
+			return "", false, errors.New("lookup failed")
+		}
+		value, found := vars[name]
+		return value, found, nil
+	}
+
+	tests := []struct {
+		name   string
+		value  string
+		expect string
+		error  string
+	}{
+		{
+			name:   "no references",
+			value:  "$HOST:8080",
+			expect: "$HOST:8080",
+		},
+		{
+			name:   "reference",
+			value:  "${HOST}:8080",
+			expect: "localhost:8080",
+		},
+		{
+			name:   "unknown reference",
+			value:  "${UNKNOWN}:8080",
+			expect: ":8080",
+		},
+		{
+			name:   "fallback",
+			value:  "${UNKNOWN:-${EMPTY:-${HOST}}}",
+			expect: "localhost",
+		},
+		{
+			name:   "literal",
+			value:  "$${HOST}",
+			expect: "${HOST}",
+		},
+		{
+			name:  "unclosed reference",
+			value: "${HOST",
+			error: `unclosed reference in "${HOST"`,
+		},
+		{
+			name:  "lookup error",
+			value: "${ERROR}",
+			error: "lookup failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := expandString(tt.value, lookup)
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, actual)
+		})
+	}
+}
+
+func TestInitWithExpandVariables(t *testing.T) {
+	t.Parallel()
+
+	type Postgres struct {
+		Host string `json:"host"`
+		Port int    `json:"port" default:"${EXPAND_DB_PORT:-5432}"`
+		User string `json:"user" default:"${EXPAND_DB_USER}"`
+		DSN  string `json:"dsn"`
+	}
+
+	var cfg struct {
+		Addr     string            `json:"addr"     default:"${EXPAND_HOSTNAME}:8080"`
+		Postgres Postgres          `json:"postgres"`
+		Literal  string            `json:"literal"`
+		Hosts    []string          `json:"hosts"    default:"${postgres.host},${EXPAND_HOSTNAME}"`
+		Labels   map[string]string `json:"labels"`
+		Replicas []Postgres        `json:"replicas" envprefix:"EXPAND_REPLICAS"`
+	}
+	cfg.Labels = map[string]string{"host": "${.addr}"}
+
+	env := testEnv(
+		"EXPAND_HOSTNAME", "pod-1",
+		"EXPAND_DB_USER", "app",
+		"EXPAND_DB_PORT", "5433",
+		"EXPAND_REPLICAS_0_HOST", "replica",
+	)
+
+	err := Init(&cfg, "testdata/expand.json", WithEnv(env), WithExpandVariables())
+	assert.NoError(t, err)
+	assert.Equal(t, "pod-1:8080", cfg.Addr)
+	assert.Equal(t, Postgres{Host: "localhost", Port: 5433, User: "app", DSN: "postgres://app@localhost:5433/db"}, cfg.Postgres)
+	assert.Equal(t, "${HOME}", cfg.Literal)
+	assert.Equal(t, []string{"localhost", "pod-1"}, cfg.Hosts)
+	assert.Equal(t, map[string]string{"host": "pod-1:8080"}, cfg.Labels)
+	assert.Equal(t, []Postgres{{Host: "replica", Port: 5433, User: "app"}}, cfg.Replicas)
+}
+
+func TestInitWithExpandVariablesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		cfg   interface{}
+		error string
+	}{
+		{
+			name: "cycle",
+			cfg: &struct {
+				A string `default:"${.b}"`
+				B string `default:"${.c}"`
+				C string `default:"${.a}"`
+			}{},
+			error: "expand A: cycle in config references: A -> B -> C -> A",
+		},
+		{
+			name: "unknown field",
+			cfg: &struct {
+				A string `default:"${postgres.host}"`
+			}{},
+			error: `expand A: config field "postgres.host" referenced by ${postgres.host} not found`,
+		},
+		{
+			name: "field reference in non string default",
+			cfg: &struct {
+				Port int `default:"${server.port}"`
+			}{},
+			error: "Port: default: config field reference ${server.port} is allowed in string values only",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Init(tt.cfg, "", WithEnv(MapEnv{}), WithExpandVariables())
+			assert.EqualError(t, err, tt.error)
+		})
+	}
+}
+
+func TestInitWithExpandVariablesElements(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host" default:"${EXPAND_ELEMENT_HOST}"`
+		DSN  string `json:"dsn"  default:"postgres://${.replicas.0.host}/db"`
+	}
+
+	var cfg struct {
+		Literal  string             `json:"literal"`
+		Replicas []Replica          `json:"replicas" envprefix:"EXPAND_ELEMENT_REPLICAS"`
+		Pools    map[string]Replica `json:"pools"    envprefix:"EXPAND_ELEMENT_POOLS"`
+	}
+
+	dir, err := ioutil.TempDir("", "expand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{
+  "literal": "$${HOME}",
+  "replicas": [{"host": "$${HOME}"}],
+  "pools": {"orders": {"host": "$${HOME}"}}
+}`})
+
+	env := testEnv(
+		"EXPAND_ELEMENT_HOST", "replica",
+		"EXPAND_ELEMENT_REPLICAS_1_HOST", "replica1",
+	)
+
+	err = Init(&cfg, filepath.Join(dir, "config.json"), WithEnv(env), WithExpandVariables())
+	assert.NoError(t, err)
+	assert.Equal(t, "${HOME}", cfg.Literal)
+	assert.Equal(t, []Replica{
+		{Host: "${HOME}", DSN: "postgres://${HOME}/db"},
+		{Host: "replica1", DSN: "postgres://${HOME}/db"},
+	}, cfg.Replicas)
+	assert.Equal(t, map[string]Replica{"orders": {Host: "${HOME}", DSN: "postgres://${HOME}/db"}}, cfg.Pools)
+}
+
+func TestJSONPathOf(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host"`
+	}
+
+	type Config struct {
+		Replicas []*Replica         `json:"replicas"`
+		Pools    map[string]Replica `json:"pools"`
+		Name     string
+	}
+
+	typ := reflect.TypeOf(Config{})
+	assert.Equal(t, "replicas.0.host", jsonPathOf(typ, "Replicas[0].Host"))
+	assert.Equal(t, "pools.orders.host", jsonPathOf(typ, "Pools[orders].Host"))
+	assert.Equal(t, "Name", jsonPathOf(typ, "Name"))
+	assert.Equal(t, "", jsonPathOf(typ, ""))
+}
@@ -0,0 +1,628 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptWidth is the maximum width of excerpt of the offending line of config file
+const excerptWidth = 80
+
+// ErrUnknownKey is reported for key of config file, which matches no field, when unknown fields are disallowed
+var ErrUnknownKey = errors.New("unknown key")
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// applyJSONConfig decodes config file, failure is reported with position and path of keys of the offending value.
+// Keys, which match no field, are reported when unknown fields are disallowed
+func (l *loader) applyJSONConfig(config interface{}, filename string) error {
+	if len(filename) == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return err
+	}
+
+	if l.schema != nil {
+		if err := validateSchema(filename, data, l.schema, reflect.TypeOf(config)); err != nil {
+			return err
+		}
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(config); err != nil {
+		return locateFileError(filename, data, reflect.TypeOf(config), err)
+	}
+
+	if l.provenance != nil {
+		l.recordFile(filename, data, reflect.TypeOf(config))
+	}
+
+	if l.disallowUnknownFields {
+		return unknownKeys(filename, data, reflect.TypeOf(config))
+	}
+	return nil
+}
+
+// recordFile records position of every leaf value of config file as origin of field
+func (l *loader) recordFile(filename string, data []byte, t reflect.Type) {
+	_ = walkJSON(data, func(v jsonValue) bool {
+		if v.path == "" {
+			return true
+		}
+		parent := ""
+		if i := strings.LastIndex(v.path, "."); i >= 0 {
+			parent = v.path[:i]
+		}
+		if pt, ok := typeByPath(t, parent); !ok || isLeafType(pt) {
+			return true
+		}
+
+		if _, path, ok := resolveJSONPath(t, v.path); ok {
+			line, column, _ := positionOf(data, v.offset)
+			l.record(path, SourceFile, fmt.Sprintf("%s:%d:%d", filename, line, column))
+		}
+		return true
+	})
+}
+
+// locateFileError finds value of config file `data`, which failed decoding to type `t`
+func locateFileError(filename string, data []byte, t reflect.Type, err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		failed          = jsonValue{offset: -1, keyOffset: -1}
+		offset    int64 = -1
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(bytes.TrimRight(data, " \t\r\n"))) - 1
+		if syntaxErr != nil {
+			offset = syntaxErr.Offset - 1
+		}
+		var walkErr *jsonWalkError
+		if errors.As(walkJSON(data, func(jsonValue) bool { return true }), &walkErr) {
+			failed.path, failed.keyOffset = walkErr.path, walkErr.keyOffset
+		}
+	case errors.As(err, &typeErr):
+		_ = walkJSON(data, func(v jsonValue) bool {
+			if v.offset >= typeErr.Offset {
+				return false
+			}
+			failed, offset = v, v.offset
+			return true
+		})
+	default:
+		// errors of `json.Unmarshaler` have no offset, so values are decoded one by one to find the failed one
+		_ = walkJSON(data, func(v jsonValue) bool {
+			f, ok := typeByPath(t, v.path)
+			if !ok || v.raw == nil {
+				return true
+			}
+			if e := json.Unmarshal(v.raw, reflect.New(f).Interface()); e != nil && e.Error() == err.Error() {
+				failed, offset = v, v.offset
+				return false
+			}
+			return true
+		})
+	}
+
+	if offset < 0 {
+		return &FieldError{Path: failed.path, Source: SourceFile, Key: filename, Err: err}
+	}
+
+	fieldErr := fileError(filename, data, failed.path, offset, err)
+	if secretLine(data, t, offset, failed) {
+		// excerpt of the offending line shows the secret
+		fieldErr.Value, fieldErr.Err = redacted, err
+	}
+	return fieldErr
+}
+
+// secretLine reports whether line of config file `data` at `offset` might show secret of type `t`.
+// Line is checked by keys of values read on it and of value `failed`, which could not be read.
+// Line, which has no keys read or has keys, which were not read as they follow the error, is treated as secret,
+// when config has any secret
+func secretLine(data []byte, t reflect.Type, offset int64, failed jsonValue) bool {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	start, end := int64(bytes.LastIndexByte(data[:offset], '\n')+1), int64(len(data))
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		end = offset + int64(i)
+	}
+	onLine := func(offset int64) bool {
+		return offset >= start && offset < end
+	}
+
+	secret, read := false, make(map[int64]bool)
+	check := func(v jsonValue) {
+		if onLine(v.keyOffset) || onLine(v.offset) {
+			secret = secret || isSecretJSONPath(t, v.path)
+			read[v.keyOffset], read[v.offset] = true, true
+		}
+	}
+	_ = walkJSON(data, func(v jsonValue) bool {
+		check(v)
+		return v.offset < end
+	})
+	check(failed)
+
+	switch {
+	case secret:
+		return true
+	case !hasSecret(t):
+		return false
+	case len(read) == 0:
+		return true
+	}
+	for i := start; i < end; i++ {
+		if _, ok := keyAt(data, i); ok && !read[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownKeys reports every key of config file `data`, which matches no field of type `t`.
+// Similar `json` name is suggested, keys nested into unknown ones are not reported.
+// Line of key is not reported for config with secrets
+func unknownKeys(filename string, data []byte, t reflect.Type) error {
+	var errs MultiError
+
+	secret := hasSecret(t)
+
+	_ = walkJSON(data, func(v jsonValue) bool {
+		if v.path == "" {
+			return true
+		}
+
+		parentPath, name := "", v.path
+		if i := strings.LastIndex(v.path, "."); i >= 0 {
+			parentPath, name = v.path[:i], v.path[i+1:]
+		}
+
+		parent, ok := typeByPath(t, parentPath)
+		if !ok || parent.Kind() != reflect.Struct || isJSONUnmarshaler(parent) {
+			return true
+		}
+		if _, ok := fieldByJSONName(parent, name); ok {
+			return true
+		}
+
+		err := ErrUnknownKey
+		if suggestion, ok := similarName(name, parent); ok {
+			err = fmt.Errorf("%w, did you mean %s?", ErrUnknownKey, suggestion)
+		}
+		fieldErr := fileError(filename, data, v.path, v.keyOffset, err)
+		if secret {
+			// mistyped key might hold a secret, so neither value nor excerpt of the line is reported
+			fieldErr.Value, fieldErr.Err = "", err
+		}
+		errs = errs.append(fieldErr)
+		return true
+	})
+
+	return errs.err()
+}
+
+// similarName returns `json` name of field of struct `t`, which is the closest to `name`
+func similarName(name string, t reflect.Type) (string, bool) {
+	var names []string
+	for _, f := range jsonFields(t) {
+		names = append(names, jsonName(f))
+	}
+	return closestName(name, names)
+}
+
+// closestName returns name, which is the closest to `name` by edit distance ignoring case.
+// Names, which differ in more than fifth of `name`, are not considered similar
+func closestName(name string, names []string) (string, bool) {
+	var (
+		closest string
+		best    = 1 + len(name)/5
+	)
+	for _, candidate := range names {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d <= best && (closest == "" || d < best) {
+			closest, best = candidate, d
+		}
+	}
+	return closest, closest != ""
+}
+
+// editDistance returns number of insertions, deletions, substitutions and transpositions of adjacent runes,
+// which turn `a` into `b`
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(s)][len(t)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// fileError reports failure of value stored under `path` of keys at `offset` of config file `data`
+func fileError(filename string, data []byte, path string, offset int64, err error) *FieldError {
+	line, column, text := positionOf(data, offset)
+	return &FieldError{
+		Path:   path,
+		Source: SourceFile,
+		Key:    fmt.Sprintf("%s:%d:%d", filename, line, column),
+		Value:  strings.TrimSpace(text),
+		Err:    &excerptError{err: err, line: text, column: column},
+	}
+}
+
+// excerptError adds excerpt of the offending line with marked column to error
+type excerptError struct {
+	err    error
+	line   string
+	column int
+}
+
+func (e *excerptError) Error() string {
+	line, column := excerpt(e.line, e.column)
+
+	marker := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, string([]rune(line)[:column-1]))
+
+	return fmt.Sprintf("%s\n\t%s\n\t%s^", e.err, line, marker)
+}
+
+func (e *excerptError) Unwrap() error {
+	return e.err
+}
+
+// excerpt cuts long line around column and returns column in the excerpt
+func excerpt(line string, column int) (string, int) {
+	runes := []rune(line)
+	if len(runes) <= excerptWidth {
+		return line, column
+	}
+
+	start := column - 1 - excerptWidth/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + excerptWidth
+	if end > len(runes) {
+		start, end = len(runes)-excerptWidth, len(runes)
+	}
+
+	text, column := string(runes[start:end]), column-start
+	if start > 0 {
+		text, column = "..."+text, column+3
+	}
+	if end < len(runes) {
+		text += "..."
+	}
+	return text, column
+}
+
+// positionOf returns line and column of byte at `offset` starting with 1 and text of the line
+func positionOf(data []byte, offset int64) (int, int, string) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	start := bytes.LastIndexByte(before, '\n') + 1
+	end := bytes.IndexByte(data[start:], '\n')
+	if end < 0 {
+		end = len(data)
+	} else {
+		end += start
+	}
+
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(data[start:offset]) + 1
+	return line, column, strings.TrimRight(string(data[start:end]), "\r")
+}
+
+// jsonValue is a value of config file stored under `path` of keys, array elements are referred by index.
+// `keyOffset` is offset of key of value or offset of array element, `raw` is set for values other than objects and arrays
+type jsonValue struct {
+	path      string
+	offset    int64
+	keyOffset int64
+	raw       []byte
+}
+
+// jsonFrame is an object or array, which values are walked
+type jsonFrame struct {
+	path      string
+	object    bool
+	expectKey bool
+	key       string
+	keyOffset int64
+	index     int
+}
+
+func (f *jsonFrame) pathOf() string {
+	if f.object {
+		return joinPath(f.path, f.key)
+	}
+	return joinPath(f.path, strconv.Itoa(f.index))
+}
+
+// next moves frame to the next value
+func (f *jsonFrame) next() {
+	if f.object {
+		f.expectKey = true
+	} else {
+		f.index++
+	}
+}
+
+// jsonWalkError is failure of reading of value stored under `path` of keys, `keyOffset` is offset of its key or -1
+type jsonWalkError struct {
+	path      string
+	keyOffset int64
+	err       error
+}
+
+func (e *jsonWalkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *jsonWalkError) Unwrap() error {
+	return e.err
+}
+
+// failedPath returns path of value, which is being read by frame `top`, when reading of `data` failed with `err`,
+// and offset of its key or -1. Key, which could not be read, e.g. as comma is missing before it, is taken at offset of error,
+// otherwise the previous key is taken, as error follows its value
+func failedPath(data []byte, top *jsonFrame, err error) (string, int64) {
+	switch {
+	case top == nil:
+		return "", -1
+	case !top.object:
+		return top.pathOf(), -1
+	case !top.expectKey:
+		return top.pathOf(), top.keyOffset
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if key, ok := keyAt(data, syntaxErr.Offset-1); ok {
+			return joinPath(top.path, key), syntaxErr.Offset - 1
+		}
+	}
+	if top.key != "" {
+		return top.pathOf(), top.keyOffset
+	}
+	return top.path, -1
+}
+
+// keyAt returns key of object, which starts at `offset` of `data`
+func keyAt(data []byte, offset int64) (string, bool) {
+	if offset < 0 || offset >= int64(len(data)) || data[offset] != '"' {
+		return "", false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data[offset:]))
+	tok, err := dec.Token()
+	key, ok := tok.(string)
+	if err != nil || !ok {
+		return "", false
+	}
+
+	rest := bytes.TrimLeft(data[offset+dec.InputOffset():], " \t\r\n")
+	return key, len(rest) > 0 && rest[0] == ':'
+}
+
+// walkJSON calls `fn` for every value of the first JSON value of `data` in order of appearance, until `fn` returns false.
+// Failure of reading is returned as `jsonWalkError` with path of value, which is being read
+func walkJSON(data []byte, fn func(v jsonValue) bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var stack []*jsonFrame
+
+	for {
+		prev := dec.InputOffset()
+		tok, err := dec.Token()
+
+		var top *jsonFrame
+		if n := len(stack); n > 0 {
+			top = stack[n-1]
+		}
+
+		if err == io.EOF && top != nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			path, keyOffset := failedPath(data, top, err)
+			return &jsonWalkError{path: path, keyOffset: keyOffset, err: err}
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return nil
+			}
+			stack[len(stack)-1].next()
+			continue
+		}
+
+		offset := prev + int64(len(data[prev:])-len(bytes.TrimLeft(data[prev:], " \t\r\n:,")))
+
+		if top != nil && top.expectKey {
+			top.key, top.keyOffset, top.expectKey = tok.(string), offset, false
+			continue
+		}
+
+		v := jsonValue{offset: offset, keyOffset: offset}
+		if top != nil {
+			v.path = top.pathOf()
+			if top.object {
+				v.keyOffset = top.keyOffset
+			}
+		}
+
+		d, composite := tok.(json.Delim)
+		if !composite {
+			v.raw = data[v.offset:dec.InputOffset()]
+		}
+
+		if !fn(v) {
+			return nil
+		}
+
+		switch {
+		case composite:
+			stack = append(stack, &jsonFrame{path: v.path, object: d == '{', expectKey: d == '{'})
+		case top == nil:
+			return nil
+		default:
+			top.next()
+		}
+	}
+}
+
+// typeByPath looks up type of value stored under path of keys of config file
+func typeByPath(t reflect.Type, path string) (reflect.Type, bool) {
+	t, _, ok := resolveJSONPath(t, path)
+	return t, ok
+}
+
+// resolveJSONPath looks up type of value stored under path of keys of config file and returns path of field names,
+// e.g. `Postgres.Replicas[0].Host` for `postgres.replicas.0.host`
+func resolveJSONPath(t reflect.Type, path string) (reflect.Type, string, bool) {
+	t = indirectType(t)
+	if path == "" {
+		return t, "", true
+	}
+	if isJSONUnmarshaler(t) {
+		return nil, "", false
+	}
+
+	name, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+
+	var (
+		next      reflect.Type
+		fieldPath string
+	)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if f, ok := fieldByJSONName(t, name); ok {
+			next, fieldPath = f.Type, f.Name
+		}
+	case reflect.Slice, reflect.Array:
+		if _, err := strconv.Atoi(name); err == nil {
+			next, fieldPath = t.Elem(), "["+name+"]"
+		}
+	case reflect.Map:
+		next, fieldPath = t.Elem(), "["+name+"]"
+	case reflect.Interface:
+		return t, path, true
+	}
+
+	if next == nil {
+		return nil, "", false
+	}
+
+	t, rest, ok := resolveJSONPath(next, rest)
+	if rest != "" && !strings.HasPrefix(rest, "[") {
+		rest = "." + rest
+	}
+	return t, fieldPath + rest, ok
+}
+
+// fieldByJSONName looks up field of struct `t` the same way as `encoding/json` does:
+// by `json` name first, then case insensitive
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	var (
+		folded reflect.StructField
+		found  bool
+	)
+	for _, f := range jsonFields(t) {
+		if jsonName(f) == name {
+			return f, true
+		}
+		if !found && strings.EqualFold(jsonName(f), name) {
+			folded, found = f, true
+		}
+	}
+	return folded, found
+}
+
+// jsonFields returns fields of struct `t`, which are decoded from config file, fields of embedded structs are promoted
+// with index of the embedded struct
+func jsonFields(t reflect.Type) (fields []reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			for _, promoted := range jsonFields(indirectType(f.Type)) {
+				promoted.Index = append([]int{i}, promoted.Index...)
+				fields = append(fields, promoted)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// isJSONUnmarshaler reports whether type decodes itself, so its keys are not matched with fields
+func isJSONUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(jsonUnmarshalerType)
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitFileErrors(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+
+	type Config struct {
+		Postgres struct {
+			Host     string    `json:"host"`
+			Port     int       `json:"port"`
+			Timeout  Duration  `json:"timeout"`
+			Replicas []Replica `json:"replicas"`
+		} `json:"postgres"`
+		Name string `json:"name"`
+	}
+
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		path    string
+		key     string
+		value   string
+		error   string
+	}{
+		{
+			name:    "type",
+			content: "{\n  \"postgres\": {\n    \"host\": \"localhost\",\n    \"port\": \"5432\"\n  }\n}",
+			path:    "postgres.port",
+			key:     "4:13",
+			value:   `"port": "5432"`,
+			error:   "json: cannot unmarshal string into Go struct field",
+		},
+		{
+			name:    "type of array element",
+			content: `{"postgres": {"replicas": [{"host": "replica0"}, {"host": 1}]}}`,
+			path:    "postgres.replicas.1.host",
+			key:     "1:59",
+			error:   "json: cannot unmarshal number into Go struct field",
+		},
+		{
+			name:    "syntax",
+			content: "{\n  \"postgres\": {\n    \"host\": \"localhost\"\n    \"port\": 5432\n  }\n}",
+			path:    "postgres.port",
+			key:     "4:5",
+			value:   `"port": 5432`,
+			error:   "invalid character '\"' after object key:value pair",
+		},
+		{
+			name:    "syntax in value of not the first key",
+			content: "{\n  \"name\": \"app\",\n  \"postgres\": {\n    \"host\": \"localhost\",\n    \"port\": \"5432,\n  }\n}",
+			path:    "postgres.port",
+			key:     "5:19",
+			value:   `"port": "5432,`,
+			error:   "invalid character '\\n' in string",
+		},
+		{
+			name:    "syntax after value of not the first key",
+			content: "{\n  \"name\": \"app\",\n  \"postgres\": {\"host\": \"localhost\", \"port\": 5432,}\n}",
+			path:    "postgres.port",
+			key:     "3:50",
+			error:   "invalid character '}' looking for beginning of object key string",
+		},
+		{
+			name:    "unexpected end",
+			content: "{\n  \"name\": \"app\",\n",
+			path:    "name",
+			key:     "2:16",
+			value:   `"name": "app",`,
+			error:   "unexpected EOF",
+		},
+		{
+			name:    "unmarshaler",
+			content: "{\n\t\"name\": \"app\",\n\t\"postgres\": {\"port\": 5432, \"timeout\": \"1 minute\"}\n}",
+			path:    "postgres.timeout",
+			key:     "3:40",
+			value:   `"postgres": {"port": 5432, "timeout": "1 minute"}`,
+			error:   `time: unknown unit " minute" in duration "1 minute"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, strings.Replace(tt.name, " ", "_", -1)+".json")
+			if err := ioutil.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg Config
+			err := Init(&cfg, filename, WithEnv(MapEnv{}))
+
+			var fieldErr *FieldError
+			if !assert.True(t, errors.As(err, &fieldErr)) {
+				return
+			}
+			assert.Equal(t, tt.path, fieldErr.Path)
+			assert.Equal(t, SourceFile, fieldErr.Source)
+			assert.Equal(t, filename+":"+tt.key, fieldErr.Key)
+			if tt.value != "" {
+				assert.Equal(t, tt.value, fieldErr.Value)
+			}
+			assert.Contains(t, fieldErr.Err.Error(), tt.error)
+		})
+	}
+}
+
+func TestFileErrorExcerpt(t *testing.T) {
+	t.Parallel()
+
+	err := &excerptError{err: errors.New("invalid"), line: "\t\"port\": \"5432\",", column: 10}
+	assert.EqualError(t, err, "invalid\n\t\t\"port\": \"5432\",\n\t\t        ^")
+
+	line := `{"name": "app", "` + strings.Repeat("a", 100) + `": 1, "postgres": {"port": "5432"}}`
+	text, column := excerpt(line, strings.Index(line, `"5432"`)+1)
+	assert.Equal(t, `...`+strings.Repeat("a", 45)+`": 1, "postgres": {"port": "5432"}}`, text)
+	assert.Equal(t, strings.Index(text, `"5432"`)+1, column)
+}
+
+func TestInitDisallowUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host"`
+	}
+
+	type Config struct {
+		Redis struct {
+			Addrs    []string `json:"addrs"`
+			PoolSize int      `json:"pool_size"`
+		} `json:"redis"`
+		Replicas []Replica        `json:"replicas"`
+		Limits   map[string]int   `json:"limits"`
+		Timeout  Duration         `json:"timeout"`
+		Ignored  string           `json:"-"`
+		Extra    *json.RawMessage `json:"extra"`
+	}
+
+	dir, err := ioutil.TempDir("", "strict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	content := `{
+  "redis": {"addrs": ["localhost:6379"], "pool_szie": 10, "POOL_SIZE": 5},
+  "replicas": [{"host": "replica0"}, {"hots": "replica1"}],
+  "limits": {"orders": 1},
+  "timeout": "1s",
+  "extra": {"any": {"key": 1}},
+  "unknown": {"nested": true},
+  "Ignored": "value"
+}`
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	assert.NoError(t, Init(&cfg, filename, WithEnv(MapEnv{})))
+
+	err = Init(&cfg, filename, WithEnv(MapEnv{}), WithDisallowUnknownFields())
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+
+	var errs MultiError
+	if !assert.True(t, errors.As(err, &errs)) {
+		return
+	}
+
+	var actual []string
+	for _, err := range errs {
+		fieldErr := err.(*FieldError)
+		actual = append(actual, fieldErr.Path+" "+strings.TrimPrefix(fieldErr.Key, filename)+" "+errors.Unwrap(fieldErr.Err).Error())
+	}
+	assert.Equal(t, []string{
+		"redis.pool_szie :2:42 unknown key, did you mean pool_size?",
+		"replicas.1.hots :3:39 unknown key, did you mean host?",
+		"unknown :7:3 unknown key",
+		"Ignored :8:3 unknown key",
+	}, actual)
+}
+
+func TestEditDistance(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, editDistance("host", "host"))
+	assert.Equal(t, 1, editDistance("hots", "host"))
+	assert.Equal(t, 1, editDistance("pool_szie", "pool_size"))
+	assert.Equal(t, 2, editDistance("port", "host"))
+	assert.Equal(t, 4, editDistance("", "host"))
+}
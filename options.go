@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Option configures the way configuration is loaded
+type Option func(*loader)
+
+// WarningHook receives non fatal messages produced while loading configuration
+type WarningHook func(message string)
+
+// loader keeps the state shared between loading steps
+type loader struct {
+	env                   Env
+	warningHook           WarningHook
+	compactSlices         bool
+	expandVariables       bool
+	secretsDir            string
+	disallowUnknownFields bool
+	strayEnv              bool
+	strayEnvErrors        bool
+	strayEnvPrefixes      []string
+	provenance            *Provenance
+	schema                []byte
+	usedEnv               map[string]bool
+	fieldEnv              map[string]bool
+	expander              *expander
+	root                  reflect.Value
+}
+
+func newLoader(opts ...Option) *loader {
+	l := &loader{env: OSEnv{}, usedEnv: make(map[string]bool), fieldEnv: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// WithEnv sets environment, which is used instead of environment of current process
+func WithEnv(env Env) Option {
+	return func(l *loader) {
+		l.env = env
+	}
+}
+
+// WithWarningHook sets a hook which is called for every warning, e.g. usage of deprecated ENV
+func WithWarningHook(hook WarningHook) Option {
+	return func(l *loader) {
+		l.warningHook = hook
+	}
+}
+
+func (l *loader) warn(format string, args ...interface{}) {
+	if l.warningHook == nil {
+		return
+	}
+	l.warningHook(fmt.Sprintf(format, args...))
+}
+
+// WithCompactSlices allows gaps between indexes of `envprefix` slices, elements are compacted keeping their order.
+// By default sparse slices cause an error
+func WithCompactSlices() Option {
+	return func(l *loader) {
+		l.compactSlices = true
+	}
+}
+
+// WithExpandVariables expands `${VAR}`, `${VAR:-fallback}` and `${path.to.field}` in `default` tags and config file values
+func WithExpandVariables() Option {
+	return func(l *loader) {
+		l.expandVariables = true
+	}
+}
+
+// WithSecretsDir reads values from directory, where every file name is a key and file content is a value,
+// e.g. `/run/secrets` or Kubernetes secret volume. Values are applied after config file and before ENV
+func WithSecretsDir(dir string) Option {
+	return func(l *loader) {
+		l.secretsDir = dir
+	}
+}
+
+// WithDisallowUnknownFields reports every key of config file, which matches no field, with similar name if any
+func WithDisallowUnknownFields() Option {
+	return func(l *loader) {
+		l.disallowUnknownFields = true
+	}
+}
+
+// WithStrayEnvWarnings reports ENV, which starts with one of `prefixes` or with `envprefix` of slice or map,
+// but matches no field, through warning hook. The closest ENV of config is suggested
+func WithStrayEnvWarnings(prefixes ...string) Option {
+	return func(l *loader) {
+		l.strayEnv = true
+		l.strayEnvPrefixes = prefixes
+	}
+}
+
+// WithStrayEnvErrors reports stray ENV the same way as `WithStrayEnvWarnings`, but as errors
+func WithStrayEnvErrors(prefixes ...string) Option {
+	return func(l *loader) {
+		l.strayEnv = true
+		l.strayEnvErrors = true
+		l.strayEnvPrefixes = prefixes
+	}
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Origin describes source of value of config field
+type Origin struct {
+	// Path is path of field names, e.g. `Postgres.Replicas[0].Host`
+	Path string
+	// Source is empty, when value was not set by any source
+	Source Source
+	// Key is ENV name, secret file or position of value in config file `file:line:col`
+	Key string
+	// Value is current value of field
+	Value string
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s = %s (%s)", o.Path, o.Value, o.source())
+}
+
+// source describes source with its key, e.g. `env POSTGRES_HOST`
+func (o Origin) source() string {
+	if o.Source == "" {
+		return "not set"
+	}
+	if o.Key == "" {
+		return string(o.Source)
+	}
+	return string(o.Source) + " " + o.Key
+}
+
+// Provenance records origin of every field of config, it is filled by `WithProvenance` option
+type Provenance struct {
+	origins map[string]Origin
+	root    reflect.Value
+}
+
+// WithProvenance records origin of every field of config to `p`
+func WithProvenance(p *Provenance) Option {
+	return func(l *loader) {
+		l.provenance = p
+	}
+}
+
+// Explain returns origin of field stored under path of field names, e.g. `Postgres.Host`.
+// Field, which is set as a whole, e.g. slice decoded from JSON ENV, is the origin of its nested fields
+func (p *Provenance) Explain(path string) (Origin, bool) {
+	t, v, ok := fieldByFieldPath(p.root, path)
+	if !ok {
+		return Origin{}, false
+	}
+	return p.originOf(path, v, isSecret(t, v.Type()) || hasSecretAncestor(p.root, path)), true
+}
+
+// Report returns origins of all leaf fields in order of declaration, elements of maps are sorted by key
+func (p *Provenance) Report() []Origin {
+	var report []Origin
+	if p.root.IsValid() {
+		walkLeaves("", reflect.StructField{}, p.root, false, func(path string, _ reflect.StructField, v reflect.Value, secret bool) {
+			report = append(report, p.originOf(path, v, secret))
+		})
+	}
+	return report
+}
+
+func (p *Provenance) String() string {
+	var b strings.Builder
+	for _, origin := range p.Report() {
+		b.WriteString(origin.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// originOf returns origin of value `v` stored under `path`, value of secret is redacted
+func (p *Provenance) originOf(path string, v reflect.Value, secret bool) Origin {
+	origin := Origin{Path: path, Value: formatValue(valueOf(v))}
+	if secret {
+		origin.Value = redacted
+	}
+	for ancestor := path; ancestor != ""; ancestor = parentFieldPath(ancestor) {
+		if o, ok := p.origins[ancestor]; ok {
+			origin.Source, origin.Key = o.Source, o.Key
+			break
+		}
+	}
+	return origin
+}
+
+// reset forgets origins recorded by previous loading of config
+func (p *Provenance) reset(root reflect.Value) {
+	p.origins = make(map[string]Origin)
+	p.root = root
+}
+
+// record remembers origin of field stored under `path`, origins of its nested fields are replaced
+func (p *Provenance) record(path string, source Source, key string) {
+	for nested := range p.origins {
+		if strings.HasPrefix(nested, path+".") || strings.HasPrefix(nested, path+"[") {
+			delete(p.origins, nested)
+		}
+	}
+	p.origins[path] = Origin{Path: path, Source: source, Key: key}
+}
+
+// renumber moves origins of elements of slice stored under `path` to new indexes, origins of removed elements are dropped
+func (p *Provenance) renumber(path string, indexes map[int]int) {
+	origins := make(map[string]Origin, len(p.origins))
+	for nested, origin := range p.origins {
+		rest := strings.TrimPrefix(nested, path+"[")
+		end := strings.Index(rest, "]")
+		if rest == nested || end < 0 {
+			origins[nested] = origin
+			continue
+		}
+
+		i, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			origins[nested] = origin
+			continue
+		}
+		if n, ok := indexes[i]; ok {
+			origin.Path = fmt.Sprintf("%s[%d]%s", path, n, rest[end+1:])
+			origins[origin.Path] = origin
+		}
+	}
+	p.origins = origins
+}
+
+func (l *loader) record(path string, source Source, key string) {
+	if l.provenance != nil {
+		l.provenance.record(path, source, key)
+	}
+}
+
+func (l *loader) renumber(path string, indexes map[int]int) {
+	if l.provenance != nil {
+		l.provenance.renumber(path, indexes)
+	}
+}
+
+// isLeafType reports whether value of type `t` is reported as a whole, structs and collections of structs are walked
+func isLeafType(t reflect.Type) bool {
+	t = indirectType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		return isTime(t)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		elem := indirectType(t.Elem())
+		return elem.Kind() != reflect.Struct || isTime(elem)
+	}
+	return true
+}
+
+// walkLeaves calls `fn` for every leaf field of `v` with its path of field names and whether its value is secret,
+// elements of slices and maps are passed with field `t`, which holds them. Fields nested in field tagged as secret
+// are secrets as well, as `secret` is passed down
+func walkLeaves(path string, t reflect.StructField, v reflect.Value, secret bool, fn func(path string, t reflect.StructField, v reflect.Value, secret bool)) {
+	if isLeafType(v.Type()) {
+		fn(path, t, v, secret || isSecret(t, v.Type()))
+		return
+	}
+
+	v = indirectWalk(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" {
+				walkLeaves(joinPath(path, f.Name), f, v.Field(i), secret || isSecretTag(f), fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkLeaves(fmt.Sprintf("%s[%d]", path, i), t, v.Index(i), secret, fn)
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			walkLeaves(fmt.Sprintf("%s[%v]", path, key), t, v.MapIndex(key), secret, fn)
+		}
+	}
+}
+
+// hasSecretAncestor reports whether field stored under `path` of field names is nested in field tagged as secret
+func hasSecretAncestor(root reflect.Value, path string) bool {
+	for ancestor := parentFieldPath(path); ancestor != ""; ancestor = parentFieldPath(ancestor) {
+		if t, _, ok := fieldByFieldPath(root, ancestor); ok && isSecretTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedMapKeys returns keys of map sorted by their string form
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+// fieldByFieldPath looks up value by path of field names, e.g. `Postgres.Replicas[0].Host` or `Pools[orders]`.
+// Field, which holds the value, is returned as well, elements of slices and maps are held by slice or map
+func fieldByFieldPath(v reflect.Value, path string) (reflect.StructField, reflect.Value, bool) {
+	var field reflect.StructField
+	for path != "" {
+		if !v.IsValid() {
+			return reflect.StructField{}, reflect.Value{}, false
+		}
+		v = indirectWalk(v)
+
+		if strings.HasPrefix(path, "[") {
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return reflect.StructField{}, reflect.Value{}, false
+			}
+			key := path[1:end]
+			path = strings.TrimPrefix(path[end+1:], ".")
+
+			switch v.Kind() {
+			case reflect.Slice, reflect.Array:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= v.Len() {
+					return reflect.StructField{}, reflect.Value{}, false
+				}
+				v = v.Index(i)
+			case reflect.Map:
+				if v.Type().Key().Kind() != reflect.String {
+					return reflect.StructField{}, reflect.Value{}, false
+				}
+				v = v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+			default:
+				return reflect.StructField{}, reflect.Value{}, false
+			}
+			continue
+		}
+
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		name := path[:end]
+		path = strings.TrimPrefix(path[end:], ".")
+
+		if v.Kind() != reflect.Struct {
+			return reflect.StructField{}, reflect.Value{}, false
+		}
+		f, ok := v.Type().FieldByName(name)
+		if !ok || f.PkgPath != "" || len(f.Index) != 1 {
+			return reflect.StructField{}, reflect.Value{}, false
+		}
+		field, v = f, v.Field(f.Index[0])
+	}
+
+	return field, v, v.IsValid()
+}
+
+// parentFieldPath returns path of struct, slice or map, which contains field stored under `path`
+func parentFieldPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		return path[:strings.LastIndex(path, "[")]
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitWithProvenance(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host" envconfig:"HOST"`
+		Port int    `json:"port" envconfig:"PORT" default:"5432"`
+	}
+
+	type Config struct {
+		Postgres struct {
+			Host     string    `json:"host"     envconfig:"PROVENANCE_POSTGRES_HOST"`
+			Port     int       `json:"port"     envconfig:"PROVENANCE_POSTGRES_PORT" default:"5432"`
+			User     string    `json:"user"     envconfig:"PROVENANCE_POSTGRES_USER"`
+			Password string    `json:"password" envconfig:"PROVENANCE_POSTGRES_PASSWORD"`
+			Replicas []Replica `json:"replicas" envprefix:"PROVENANCE_REPLICAS"`
+		} `json:"postgres"`
+		Redis struct {
+			Addrs []string `json:"addrs" envconfig:"PROVENANCE_REDIS_ADDRS"`
+		} `json:"redis"`
+		Mirrors []Replica `json:"mirrors" envconfig:"PROVENANCE_MIRRORS"`
+		Name    string    `json:"name"`
+	}
+
+	dir, err := ioutil.TempDir("", "provenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	content := `{
+  "postgres": {
+    "host": "localhost",
+    "replicas": [{"host": "replica0"}, {"host": "replica1"}, {"host": "replica2"}]
+  },
+  "redis": {"addrs": ["localhost:6379"]}
+}`
+	helperWriteFiles(t, dir, map[string]string{
+		"config.json": content,
+		"user":        "app",
+	})
+	secrets := filepath.Join(dir, "secrets")
+	helperWriteFiles(t, secrets, map[string]string{"PROVENANCE_POSTGRES_PASSWORD": "secret"})
+
+	env := testEnv(
+		"PROVENANCE_POSTGRES_PORT", "5433",
+		"PROVENANCE_POSTGRES_USER_FILE", filepath.Join(dir, "user"),
+		"PROVENANCE_REPLICAS_0_DELETE", "true",
+		"PROVENANCE_REPLICAS_2_PORT", "5434",
+		"PROVENANCE_MIRRORS", `[{"host": "mirror0"}]`,
+	)
+
+	var (
+		cfg Config
+		p   Provenance
+	)
+	err = Init(&cfg, filename, WithEnv(env), WithSecretsDir(secrets), WithProvenance(&p))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		path   string
+		origin Origin
+	}{
+		{
+			path:   "Postgres.Host",
+			origin: Origin{Source: SourceFile, Key: filename + ":3:13", Value: `"localhost"`},
+		},
+		{
+			path:   "Postgres.Port",
+			origin: Origin{Source: SourceEnv, Key: "PROVENANCE_POSTGRES_PORT", Value: "5433"},
+		},
+		{
+			path:   "Postgres.User",
+			origin: Origin{Source: SourceEnv, Key: "PROVENANCE_POSTGRES_USER_FILE", Value: `"app"`},
+		},
+		{
+			path:   "Postgres.Password",
+			origin: Origin{Source: SourceSecret, Key: filepath.Join(secrets, "PROVENANCE_POSTGRES_PASSWORD"), Value: `"secret"`},
+		},
+		{
+			path:   "Postgres.Replicas[0].Host",
+			origin: Origin{Source: SourceFile, Key: filename + ":4:49", Value: `"replica1"`},
+		},
+		{
+			path:   "Postgres.Replicas[1].Port",
+			origin: Origin{Source: SourceEnv, Key: "PROVENANCE_REPLICAS_2_PORT", Value: "5434"},
+		},
+		{
+			path:   "Postgres.Replicas[1].Host",
+			origin: Origin{Source: SourceFile, Key: filename + ":4:71", Value: `"replica2"`},
+		},
+		{
+			path:   "Redis.Addrs",
+			origin: Origin{Source: SourceFile, Key: filename + ":6:22", Value: "[localhost:6379]"},
+		},
+		{
+			path:   "Mirrors[0].Host",
+			origin: Origin{Source: SourceEnv, Key: "PROVENANCE_MIRRORS", Value: `"mirror0"`},
+		},
+		{
+			path:   "Mirrors[0].Port",
+			origin: Origin{Source: SourceEnv, Key: "PROVENANCE_MIRRORS", Value: "0"},
+		},
+		{
+			path:   "Name",
+			origin: Origin{Value: `""`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			origin, ok := p.Explain(tt.path)
+			assert.True(t, ok)
+			tt.origin.Path = tt.path
+			assert.Equal(t, tt.origin, origin)
+		})
+	}
+
+	t.Run("unknown path", func(t *testing.T) {
+		_, ok := p.Explain("Postgres.Replicas[2].Host")
+		assert.False(t, ok)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		report := p.Report()
+		if assert.Len(t, report, 12) {
+			assert.Equal(t, "Postgres.Port = 5433 (env PROVENANCE_POSTGRES_PORT)", report[1].String())
+			assert.Equal(t, "Postgres.Replicas[0].Port = 5432 (default)", report[5].String())
+			assert.Equal(t, `Name = "" (not set)`, report[11].String())
+		}
+	})
+}
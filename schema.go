@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaDialect is the version of JSON Schema
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches duration accepted by `time.ParseDuration`
+const durationPattern = `^[-+]?(0|([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+$`
+
+// jsonSchema is a subset of JSON Schema keywords, which describe config
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *float64               `json:"minLength,omitempty"`
+	MaxLength            *float64               `json:"maxLength,omitempty"`
+	MinItems             *float64               `json:"minItems,omitempty"`
+	MaxItems             *float64               `json:"maxItems,omitempty"`
+	MinProperties        *float64               `json:"minProperties,omitempty"`
+	MaxProperties        *float64               `json:"maxProperties,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+}
+
+// Schema generates JSON Schema (draft 2020-12) of config file, `config` is a struct or a pointer to struct.
+// Properties are named by `json` tag, `default`, `desc` and `usage` tags are kept, rules of `validate` tag
+// become keywords. Fields with `required` tag are required, unless they have default or ENV, which might fill them up
+func Schema(config interface{}) ([]byte, error) {
+	t := reflect.TypeOf(config)
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config should be a struct or a pointer to struct, got %T", config)
+	}
+
+	schema := schemaOf(reflect.StructField{}, t, false)
+	schema.Schema = schemaDialect
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// schemaOf describes value of field `t` of type `typ`, value is filled by `env`, when field or its ancestor has ENV,
+// including elements of `envprefix` slices and maps, so its fields are not required in config file
+func schemaOf(t reflect.StructField, typ reflect.Type, env bool) *jsonSchema {
+	typ = indirectType(typ)
+	schema := &jsonSchema{}
+
+	switch {
+	case typ == timeType:
+		schema.Type, schema.Format = "string", "date-time"
+	case typ == durationCustomType:
+		schema.Type, schema.Pattern = "string", durationPattern
+	case isJSONUnmarshaler(typ):
+		// value decodes itself, so its format is unknown
+	default:
+		switch typ.Kind() {
+		case reflect.String:
+			schema.Type = "string"
+		case reflect.Bool:
+			schema.Type = "boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			schema.Type = "integer"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			schema.Type, schema.Minimum = "integer", float64Ptr(0)
+		case reflect.Float32, reflect.Float64:
+			schema.Type = "number"
+		case reflect.Slice, reflect.Array:
+			schema.Type, schema.Items = "array", schemaOf(reflect.StructField{}, typ.Elem(), env)
+		case reflect.Map:
+			schema.Type, schema.AdditionalProperties = "object", schemaOf(reflect.StructField{}, typ.Elem(), env)
+		case reflect.Struct:
+			schema.Type, schema.Properties = "object", make(map[string]*jsonSchema)
+			for _, f := range jsonFields(typ) {
+				_, prefixed := f.Tag.Lookup(envPrefixTag)
+				fieldEnv := env || prefixed || len(envNames(f)) > 0
+				schema.Properties[jsonName(f)] = schemaOf(f, f.Type, fieldEnv)
+				if isRequired(f) && f.Tag.Get(defaultTag) == "" && !fieldEnv {
+					schema.Required = append(schema.Required, jsonName(f))
+				}
+			}
+		}
+	}
+
+	schema.Description = t.Tag.Get(descTag)
+	if schema.Description == "" {
+		schema.Description = t.Tag.Get(usageTag)
+	}
+	schema.WriteOnly = isTrue(t.Tag.Get(secretTag)) || typ == secretType
+	if value, ok := t.Tag.Lookup(defaultTag); ok && !schema.WriteOnly {
+		schema.Default = schemaValue(t, typ, value)
+	}
+	schema.addRules(typ, t.Tag.Get(validateTag))
+
+	return schema
+}
+
+// addRules adds keywords of rules of `validate` tag, rules of strings apply to items of slices.
+// Rules, which have no keyword, e.g. `file_exists` or `required_if`, are skipped
+func (s *jsonSchema) addRules(typ reflect.Type, tag string) {
+	for _, rule := range (listFormat{sep: ",", trim: true, skipEmpty: true}).split(tag) {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		target := s
+		if s.Items != nil && name != "min" && name != "max" {
+			target = s.Items
+		}
+
+		switch name {
+		case "min", "max":
+			s.addLimit(typ, name, arg)
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				target.Enum = append(target.Enum, schemaValue(reflect.StructField{}, indirectType(typ), option))
+			}
+		case "regexp":
+			target.Pattern = arg
+		case "url":
+			target.Format = "uri"
+		case "email":
+			target.Format = "email"
+		case "ip":
+			target.AnyOf = []*jsonSchema{{Format: "ipv4"}, {Format: "ipv6"}}
+		}
+	}
+}
+
+// addLimit adds keyword of `min` or `max` rule by kind of value, limits of `Duration` have no keyword,
+// as it is written as string
+func (s *jsonSchema) addLimit(typ reflect.Type, name, arg string) {
+	typ = indirectType(typ)
+	if typ == durationType {
+		// `time.Duration` is written as number of nanoseconds
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return
+		}
+		arg = strconv.FormatInt(int64(d), 10)
+	}
+
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+
+	var min, max **float64
+	switch {
+	case typ == durationCustomType:
+		return
+	case typ.Kind() == reflect.String:
+		min, max = &s.MinLength, &s.MaxLength
+	case typ.Kind() == reflect.Slice, typ.Kind() == reflect.Array:
+		min, max = &s.MinItems, &s.MaxItems
+	case typ.Kind() == reflect.Map:
+		min, max = &s.MinProperties, &s.MaxProperties
+	default:
+		min, max = &s.Minimum, &s.Maximum
+	}
+
+	if name == "min" {
+		*min = float64Ptr(limit)
+	} else {
+		*max = float64Ptr(limit)
+	}
+}
+
+// schemaValue converts default or option of field to JSON value, value, which could not be converted, is kept as string
+func schemaValue(t reflect.StructField, typ reflect.Type, value string) interface{} {
+	switch typ = indirectType(typ); typ {
+	case durationType:
+		if d, err := time.ParseDuration(value); err == nil {
+			return int64(d)
+		}
+		return value
+	case timeType, durationCustomType:
+		return value
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return isTrue(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return u
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.String {
+			return listFormatOf(t).split(value)
+		}
+	}
+	return value
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
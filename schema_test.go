@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host" required:"true" validate:"hostport"`
+		Port uint16 `json:"port" default:"5432" validate:"max=65535"`
+	}
+
+	type Config struct {
+		Name     string `json:"name" required:"true" desc:"Name of service" validate:"min=3,regexp=^[a-z]+$"`
+		Postgres struct {
+			Host     string    `json:"host"     required:"true" default:"localhost"`
+			Password Secret    `json:"password" default:"12345"`
+			Replicas []Replica `json:"replicas" validate:"max=3"`
+		} `json:"postgres"`
+		Redis struct {
+			Addrs []string `json:"addrs" default:"a:1,b:2" validate:"min=1,url"`
+		} `json:"redis"`
+		Mode    string            `json:"mode"    default:"dev" validate:"oneof=dev prod"`
+		Level   int               `json:"level"   validate:"oneof=1 2 3"`
+		Limits  map[string]int    `json:"limits"  validate:"max=10"`
+		Timeout Duration          `json:"timeout" default:"2s" validate:"min=1s"`
+		Idle    time.Duration     `json:"idle"    default:"1m" validate:"min=1s"`
+		Started time.Time         `json:"started"`
+		Ratio   float64           `json:"ratio"   usage:"Ratio of sampling" validate:"min=0,max=1"`
+		Debug   bool              `json:"debug"   default:"true"`
+		Extra   json.RawMessage   `json:"extra"`
+		Labels  map[string]string `json:"-"`
+	}
+
+	b, err := Schema(&Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "debug": {
+      "type": "boolean",
+      "default": true
+    },
+    "extra": {},
+    "idle": {
+      "type": "integer",
+      "default": 60000000000,
+      "minimum": 1000000000
+    },
+    "level": {
+      "type": "integer",
+      "enum": [
+        1,
+        2,
+        3
+      ]
+    },
+    "limits": {
+      "type": "object",
+      "maxProperties": 10,
+      "additionalProperties": {
+        "type": "integer"
+      }
+    },
+    "mode": {
+      "type": "string",
+      "enum": [
+        "dev",
+        "prod"
+      ],
+      "default": "dev"
+    },
+    "name": {
+      "type": "string",
+      "description": "Name of service",
+      "pattern": "^[a-z]+$",
+      "minLength": 3
+    },
+    "postgres": {
+      "type": "object",
+      "properties": {
+        "host": {
+          "type": "string",
+          "default": "localhost"
+        },
+        "password": {
+          "type": "string",
+          "writeOnly": true
+        },
+        "replicas": {
+          "type": "array",
+          "maxItems": 3,
+          "items": {
+            "type": "object",
+            "properties": {
+              "host": {
+                "type": "string"
+              },
+              "port": {
+                "type": "integer",
+                "default": 5432,
+                "minimum": 0,
+                "maximum": 65535
+              }
+            },
+            "required": [
+              "host"
+            ]
+          }
+        }
+      }
+    },
+    "ratio": {
+      "type": "number",
+      "description": "Ratio of sampling",
+      "minimum": 0,
+      "maximum": 1
+    },
+    "redis": {
+      "type": "object",
+      "properties": {
+        "addrs": {
+          "type": "array",
+          "default": [
+            "a:1",
+            "b:2"
+          ],
+          "minItems": 1,
+          "items": {
+            "type": "string",
+            "format": "uri"
+          }
+        }
+      }
+    },
+    "started": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "timeout": {
+      "type": "string",
+      "pattern": "^[-+]?(0|([0-9]+(\\.[0-9]*)?|\\.[0-9]+)(ns|us|µs|ms|s|m|h))+$",
+      "default": "2s"
+    }
+  },
+  "required": [
+    "name"
+  ]
+}
+`, string(b))
+
+	for _, d := range []string{"0", "2s", "1h30m", "1.5h", "-300ms", "1µs"} {
+		assert.Regexp(t, durationPattern, d)
+	}
+	assert.NotRegexp(t, durationPattern, "1 minute")
+
+	_, err = Schema("config")
+	assert.EqualError(t, err, "config should be a struct or a pointer to struct, got string")
+}
+
+func TestSchemaRequiredEnv(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host" required:"true"`
+	}
+
+	type Config struct {
+		Name     string `json:"name"  required:"true"`
+		Token    string `json:"token" required:"true" envconfig:"SCHEMA_TOKEN"`
+		Postgres struct {
+			Host string `json:"host" required:"true"`
+		} `json:"postgres" envconfig:"SCHEMA_POSTGRES"`
+		Replicas []Replica `json:"replicas" envprefix:"SCHEMA_REPLICAS"`
+	}
+
+	b, err := Schema(Config{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var schema jsonSchema
+	if !assert.NoError(t, json.Unmarshal(b, &schema)) {
+		return
+	}
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.Empty(t, schema.Properties["postgres"].Required)
+	assert.Empty(t, schema.Properties["replicas"].Items.Required)
+
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{"name": "service"}`})
+
+	var cfg Config
+	err = Init(&cfg, filename, WithEnv(MapEnv{"SCHEMA_TOKEN": "abc", "SCHEMA_POSTGRES": `{"host": "localhost"}`}), WithSchema(b))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", cfg.Token)
+}
@@ -0,0 +1,666 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSchema is reported for value of config file, which violates JSON Schema passed by `WithSchema` option
+var ErrSchema = errors.New("violates schema")
+
+// WithSchema validates config file against JSON Schema before it is decoded, so file is rejected,
+// even when config struct would accept it. Violations are reported with path of field and JSON pointer of the offending value.
+// Keywords of draft 2020-12 are supported except those listed in `unsupportedKeywords`, which fail validation,
+// references are JSON pointers within the same schema
+func WithSchema(schema []byte) Option {
+	return func(l *loader) {
+		l.schema = schema
+	}
+}
+
+// unsupportedKeywords are keywords of draft 2020-12, which are not applied, so schema with them is rejected
+// instead of being silently loosened. `$id` is allowed at root of schema only, where it does not change references
+var unsupportedKeywords = []string{
+	"$id", "$anchor", "$dynamicAnchor", "$dynamicRef",
+	"dependentSchemas", "minContains", "maxContains", "unevaluatedItems", "unevaluatedProperties",
+}
+
+// schemaViolation is a value at JSON `pointer`, which violates keyword of schema
+type schemaViolation struct {
+	pointer string
+	message string
+}
+
+// schemaValidator validates decoded JSON against JSON Schema `root`, `refs` are references,
+// which are being applied to values under JSON pointers, so cycle of references is detected
+type schemaValidator struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+	refs     map[[2]string]bool
+}
+
+// validateSchema reports every value of config file `data` of config type `t`, which violates JSON Schema `schema`.
+// File, which is not valid JSON, is not validated, so its syntax error is reported by decoding
+func validateSchema(filename string, data, schema []byte, t reflect.Type) error {
+	root, err := decodeJSON(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %s", err)
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil
+	}
+
+	s := &schemaValidator{root: root, patterns: make(map[string]*regexp.Regexp), refs: make(map[[2]string]bool)}
+	violations, err := s.validate(root, doc, "")
+	if err != nil {
+		return fmt.Errorf("invalid schema: %s", err)
+	}
+
+	offsets := make(map[string]int64)
+	_ = walkJSON(data, func(v jsonValue) bool {
+		offsets[v.path] = v.offset
+		return true
+	})
+
+	var errs MultiError
+	for _, v := range violations {
+		path := pointerToPath(v.pointer)
+		line, column, _ := positionOf(data, offsets[path])
+		// value, which matches no field, is referred by path of keys
+		if _, fieldPath, ok := resolveJSONPath(t, path); ok {
+			path = fieldPath
+		}
+		err := fmt.Errorf("%w: %s", ErrSchema, v.message)
+		if v.pointer != "" {
+			err = fmt.Errorf("%w at %s: %s", ErrSchema, v.pointer, v.message)
+		}
+		errs = errs.append(&FieldError{
+			Path:   path,
+			Source: SourceFile,
+			Key:    fmt.Sprintf("%s:%d:%d", filename, line, column),
+			Err:    err,
+		})
+	}
+	return errs.err()
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// validate returns violations of `schema` by `value` stored under JSON `pointer`,
+// error is returned for schema, which could not be applied
+func (s *schemaValidator) validate(schema, value interface{}, pointer string) ([]schemaViolation, error) {
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			return []schemaViolation{{pointer, "value is not allowed"}}, nil
+		}
+		return nil, nil
+	case map[string]interface{}:
+		return s.validateObject(schema, value, pointer)
+	}
+	return nil, fmt.Errorf("schema at %q should be an object or a boolean", pointer)
+}
+
+func (s *schemaValidator) validateObject(schema map[string]interface{}, value interface{}, pointer string) ([]schemaViolation, error) {
+	var violations []schemaViolation
+	report := func(format string, args ...interface{}) {
+		violations = append(violations, schemaViolation{pointer, fmt.Sprintf(format, args...)})
+	}
+
+	for _, keyword := range unsupportedKeywords {
+		if _, ok := schema[keyword]; ok && (keyword != "$id" || !s.isRoot(schema)) {
+			return nil, fmt.Errorf("keyword %s is not supported", keyword)
+		}
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		// reference applied again to the same value would never end
+		visit := [2]string{ref, pointer}
+		if s.refs[visit] {
+			return nil, fmt.Errorf("reference %q is cyclic", ref)
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		s.refs[visit] = true
+		nested, err := s.validate(target, value, pointer)
+		delete(s.refs, visit)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, nested...)
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		report("should be %s, got %s", typeNames(t), jsonType(value))
+		// keywords of other types would not apply
+		return violations, nil
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsJSON(enum, value) {
+		report("should be one of %s", compactJSON(enum))
+	}
+	if c, ok := schema["const"]; ok && !equalJSON(c, value) {
+		report("should be %s", compactJSON(c))
+	}
+
+	var err error
+	switch value := value.(type) {
+	case json.Number:
+		s.validateNumber(schema, value, report)
+	case string:
+		err = s.validateString(schema, value, report)
+	case []interface{}:
+		var nested []schemaViolation
+		nested, err = s.validateArray(schema, value, pointer, report)
+		violations = append(violations, nested...)
+	case map[string]interface{}:
+		var nested []schemaViolation
+		nested, err = s.validateProperties(schema, value, pointer, report)
+		violations = append(violations, nested...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nested, err := s.validateCombinators(schema, value, pointer, report)
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, nested...), nil
+}
+
+func (s *schemaValidator) validateNumber(schema map[string]interface{}, value json.Number, report func(string, ...interface{})) {
+	n, _ := value.Float64()
+	if limit, ok := schemaNumber(schema, "minimum"); ok && n < limit {
+		report("should be >= %v", limit)
+	}
+	if limit, ok := schemaNumber(schema, "maximum"); ok && n > limit {
+		report("should be <= %v", limit)
+	}
+	if limit, ok := schemaNumber(schema, "exclusiveMinimum"); ok && n <= limit {
+		report("should be > %v", limit)
+	}
+	if limit, ok := schemaNumber(schema, "exclusiveMaximum"); ok && n >= limit {
+		report("should be < %v", limit)
+	}
+	if divisor, ok := schemaNumber(schema, "multipleOf"); ok && divisor > 0 {
+		if q := n / divisor; math.Abs(q-math.Round(q)) > 1e-9 {
+			report("should be multiple of %v", divisor)
+		}
+	}
+}
+
+func (s *schemaValidator) validateString(schema map[string]interface{}, value string, report func(string, ...interface{})) error {
+	length := float64(utf8.RuneCountInString(value))
+	if limit, ok := schemaNumber(schema, "minLength"); ok && length < limit {
+		report("should have at least %v characters", limit)
+	}
+	if limit, ok := schemaNumber(schema, "maxLength"); ok && length > limit {
+		report("should have at most %v characters", limit)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := s.compile(pattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			report("should match pattern %s", pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !matchesFormat(format, value) {
+		report("should be %s", format)
+	}
+	return nil
+}
+
+func (s *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, pointer string,
+	report func(string, ...interface{})) ([]schemaViolation, error) {
+	if limit, ok := schemaNumber(schema, "minItems"); ok && float64(len(value)) < limit {
+		report("should have at least %v items", limit)
+	}
+	if limit, ok := schemaNumber(schema, "maxItems"); ok && float64(len(value)) > limit {
+		report("should have at most %v items", limit)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			if containsJSON(value[:i], value[i]) {
+				report("item %d is not unique", i)
+			}
+		}
+	}
+
+	var violations []schemaViolation
+
+	prefixItems, _ := schema["prefixItems"].([]interface{})
+	for i, item := range value {
+		itemSchema, ok := schema["items"]
+		if i < len(prefixItems) {
+			itemSchema, ok = prefixItems[i], true
+		}
+		if !ok {
+			continue
+		}
+		nested, err := s.validate(itemSchema, item, pointer+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, nested...)
+	}
+
+	if contains, ok := schema["contains"]; ok {
+		found := false
+		for i, item := range value {
+			if nested, err := s.validate(contains, item, pointer+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			} else if len(nested) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("should contain item matching schema")
+		}
+	}
+
+	return violations, nil
+}
+
+func (s *schemaValidator) validateProperties(schema map[string]interface{}, value map[string]interface{}, pointer string,
+	report func(string, ...interface{})) ([]schemaViolation, error) {
+	if limit, ok := schemaNumber(schema, "minProperties"); ok && float64(len(value)) < limit {
+		report("should have at least %v properties", limit)
+	}
+	if limit, ok := schemaNumber(schema, "maxProperties"); ok && float64(len(value)) > limit {
+		report("should have at most %v properties", limit)
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, found := value[name]; !found {
+					report("missing required property %q", name)
+				}
+			}
+		}
+	}
+	if dependent, ok := schema["dependentRequired"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(dependent) {
+			if _, found := value[name]; !found {
+				continue
+			}
+			required, _ := dependent[name].([]interface{})
+			for _, other := range required {
+				if other, ok := other.(string); ok {
+					if _, found := value[other]; !found {
+						report("missing property %q required by %q", other, name)
+					}
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	patternProperties, _ := schema["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	propertyNames, hasPropertyNames := schema["propertyNames"]
+
+	var violations []schemaViolation
+	apply := func(schema, value interface{}, pointer string) error {
+		nested, err := s.validate(schema, value, pointer)
+		violations = append(violations, nested...)
+		return err
+	}
+
+	for _, name := range sortedKeys(value) {
+		propertyPointer := pointer + "/" + escapePointer(name)
+
+		if hasPropertyNames {
+			if nested, err := s.validate(propertyNames, name, propertyPointer); err != nil {
+				return nil, err
+			} else if len(nested) > 0 {
+				report("property name %q is not allowed", name)
+			}
+		}
+
+		matched := false
+		if propertySchema, ok := properties[name]; ok {
+			matched = true
+			if err := apply(propertySchema, value[name], propertyPointer); err != nil {
+				return nil, err
+			}
+		}
+		for _, pattern := range sortedKeys(patternProperties) {
+			re, err := s.compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			if re.MatchString(name) {
+				matched = true
+				if err := apply(patternProperties[pattern], value[name], propertyPointer); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				violations = append(violations, schemaViolation{propertyPointer, "property is not allowed"})
+				continue
+			}
+			if err := apply(additional, value[name], propertyPointer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// validateCombinators applies `allOf`, `anyOf`, `oneOf`, `not` and `if`, violations of `allOf` and `then`/`else`
+// are reported as they are, others are summarised
+func (s *schemaValidator) validateCombinators(schema map[string]interface{}, value interface{}, pointer string,
+	report func(string, ...interface{})) ([]schemaViolation, error) {
+	var violations []schemaViolation
+
+	matches := func(schema interface{}) (bool, error) {
+		nested, err := s.validate(schema, value, pointer)
+		return len(nested) == 0, err
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			nested, err := s.validate(sub, value, pointer)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, nested...)
+		}
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		subs, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range subs {
+			ok, err := matches(sub)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched++
+			}
+		}
+		switch {
+		case matched == 0:
+			report("should match %s of schemas", strings.TrimSuffix(keyword, "Of"))
+		case keyword == "oneOf" && matched > 1:
+			report("should match exactly one of schemas, matches %d", matched)
+		}
+	}
+
+	if not, ok := schema["not"]; ok {
+		ok, err := matches(not)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			report("should not match schema")
+		}
+	}
+
+	if condition, ok := schema["if"]; ok {
+		ok, err := matches(condition)
+		if err != nil {
+			return nil, err
+		}
+		branch, found := schema["else"]
+		if ok {
+			branch, found = schema["then"]
+		}
+		if found {
+			nested, err := s.validate(branch, value, pointer)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, nested...)
+		}
+	}
+
+	return violations, nil
+}
+
+// isRoot reports whether `schema` is the root schema
+func (s *schemaValidator) isRoot(schema map[string]interface{}) bool {
+	root, ok := s.root.(map[string]interface{})
+	return ok && reflect.ValueOf(root).Pointer() == reflect.ValueOf(schema).Pointer()
+}
+
+// resolve looks up schema referenced by JSON pointer within root schema, e.g. `#/$defs/replica`
+func (s *schemaValidator) resolve(ref string) (interface{}, error) {
+	switch {
+	case !strings.HasPrefix(ref, "#"):
+		return nil, fmt.Errorf("reference %q to other document is not supported", ref)
+	case ref != "#" && !strings.HasPrefix(ref, "#/"):
+		return nil, fmt.Errorf("reference %q is not a JSON pointer", ref)
+	}
+
+	target := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := target.(type) {
+		case map[string]interface{}:
+			target = node[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("reference %q is not found", ref)
+			}
+			target = node[i]
+		default:
+			target = nil
+		}
+		if target == nil {
+			return nil, fmt.Errorf("reference %q is not found", ref)
+		}
+	}
+	return target, nil
+}
+
+func (s *schemaValidator) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %s", pattern, err)
+	}
+	s.patterns[pattern] = re
+	return re, nil
+}
+
+func matchesType(t, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonType(value)
+		return actual == t || t == "number" && actual == "integer"
+	case []interface{}:
+		for _, name := range t {
+			if matchesType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// jsonType returns type of decoded JSON value, number without fraction is integer
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, err := value.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func typeNames(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		var parts []string
+		for _, name := range names {
+			parts = append(parts, fmt.Sprint(name))
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// matchesFormat checks known formats, unknown ones are annotations only
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "email":
+		return isEmail(value)
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		return net.ParseIP(value) != nil && strings.Contains(value, ":")
+	case "regex":
+		_, err := regexp.Compile(value)
+		return err == nil
+	}
+	return true
+}
+
+func schemaNumber(schema map[string]interface{}, keyword string) (float64, bool) {
+	n, ok := schema[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// equalJSON compares decoded JSON values, numbers are compared by value
+func equalJSON(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+
+	switch a := a.(type) {
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsJSON(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalJSON(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compactJSON(value interface{}) string {
+	b, err := marshalJSON(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapePointer escapes token of JSON pointer
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// pointerToPath converts JSON pointer to path of keys separated by dot, e.g. `/postgres/port` to `postgres.port`
+func pointerToPath(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return strings.Join(tokens, ".")
+}
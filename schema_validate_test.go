@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSchema(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+
+	type Config struct {
+		Name     string            `json:"name"`
+		Port     int               `json:"port"`
+		Mode     string            `json:"mode"`
+		Tags     []string          `json:"tags"`
+		Replicas []Replica         `json:"replicas"`
+		Labels   map[string]string `json:"labels"`
+		Password Secret            `json:"password"`
+	}
+
+	schema := []byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "minLength": 3},
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+    "mode": {"enum": ["dev", "prod"]},
+    "tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "uniqueItems": true},
+    "replicas": {"type": "array", "items": {"$ref": "#/$defs/replica"}},
+    "labels": {"type": "object", "propertyNames": {"maxLength": 4}, "additionalProperties": {"type": "string"}},
+    "password": {"type": "string", "minLength": 8}
+  },
+  "$defs": {
+    "replica": {
+      "type": "object",
+      "required": ["host"],
+      "properties": {
+        "host": {"anyOf": [{"format": "ipv4"}, {"format": "ipv6"}]},
+        "port": {"type": "integer", "exclusiveMinimum": 0}
+      }
+    }
+  }
+}`)
+
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		schema  []byte
+		errors  []string
+	}{
+		{
+			name:    "valid",
+			content: `{"name": "app", "port": 8080, "replicas": [{"host": "10.0.0.1", "port": 5432}], "labels": {"env": "dev"}}`,
+			schema:  schema,
+		},
+		{
+			name: "violations",
+			content: `{
+  "name": "ap",
+  "port": 70000,
+  "mode": "test",
+  "tags": ["a", "B", "a"],
+  "replicas": [{"host": "10.0.0.1"}, {"host": "db", "port": 0}],
+  "labels": {"environment": "dev"},
+  "password": "s3cr3t",
+  "debug": true
+}`,
+			schema: schema,
+			errors: []string{
+				`debug: file {file}:9:12: violates schema at /debug: property is not allowed`,
+				`Labels: file {file}:7:13: violates schema at /labels: property name "environment" is not allowed`,
+				`Mode: file {file}:4:11: violates schema at /mode: should be one of ["dev","prod"]`,
+				`Name: file {file}:2:11: violates schema at /name: should have at least 3 characters`,
+				`Password: file {file}:8:15: violates schema at /password: should have at least 8 characters`,
+				`Port: file {file}:3:11: violates schema at /port: should be <= 65535`,
+				`Replicas[1].Host: file {file}:6:47: violates schema at /replicas/1/host: should match any of schemas`,
+				`Replicas[1].Port: file {file}:6:61: violates schema at /replicas/1/port: should be > 0`,
+				`Tags: file {file}:5:11: violates schema at /tags: item 2 is not unique`,
+				`Tags[1]: file {file}:5:17: violates schema at /tags/1: should match pattern ^[a-z]+$`,
+			},
+		},
+		{
+			name:    "root",
+			content: `[]`,
+			schema:  schema,
+			errors:  []string{`file {file}:1:1: violates schema: should be object, got array`},
+		},
+		{
+			name:    "missing required",
+			content: `{"port": 1.5}`,
+			schema:  schema,
+			errors: []string{
+				`file {file}:1:1: violates schema: missing required property "name"`,
+				`Port: file {file}:1:10: violates schema at /port: should be integer, got number`,
+			},
+		},
+		{
+			name:    "invalid JSON is reported by decoding",
+			content: `{"name": }`,
+			schema:  schema,
+			errors:  []string{`invalid character '}' looking for beginning of value`},
+		},
+		{
+			name:    "invalid schema",
+			content: `{"name": "app"}`,
+			schema:  []byte(`{"properties": {"name": {"pattern": "("}}}`),
+			errors:  []string{"invalid schema: pattern \"(\": error parsing regexp"},
+		},
+		{
+			name:    "unresolved reference",
+			content: `{"name": "app"}`,
+			schema:  []byte(`{"$ref": "#/$defs/config"}`),
+			errors:  []string{`invalid schema: reference "#/$defs/config" is not found`},
+		},
+		{
+			name:    "reference to anchor",
+			content: `{"name": "app"}`,
+			schema:  []byte(`{"$ref": "#config", "$defs": {"config": {"$anchor": "config"}}}`),
+			errors:  []string{`invalid schema: reference "#config" is not a JSON pointer`},
+		},
+		{
+			name:    "cyclic reference",
+			content: `{"name": "app"}`,
+			schema:  []byte(`{"$ref": "#"}`),
+			errors:  []string{`invalid schema: reference "#" is cyclic`},
+		},
+		{
+			name:    "unsupported keyword",
+			content: `{"name": "app", "tags": ["a"]}`,
+			schema:  []byte(`{"properties": {"tags": {"contains": {"const": "a"}, "maxContains": 1}}}`),
+			errors:  []string{`invalid schema: keyword maxContains is not supported`},
+		},
+		{
+			name:    "id of nested schema",
+			content: `{"name": "app"}`,
+			schema:  []byte(`{"$id": "https://example.com/config", "properties": {"name": {"$id": "name"}}}`),
+			errors:  []string{`invalid schema: keyword $id is not supported`},
+		},
+		{
+			name:    "id of root schema",
+			content: `{"name": "app", "replicas": [{"host": "10.0.0.1"}]}`,
+			schema:  []byte(`{"$id": "https://example.com/config", "properties": {"replicas": {"items": {"$ref": "#/$defs/replica"}}}, "$defs": {"replica": {"properties": {"replicas": {"$ref": "#/$defs/replica"}}}}}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name+".json")
+			if err := ioutil.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg Config
+			err := Init(&cfg, filename, WithEnv(MapEnv{}), WithSchema(tt.schema))
+			if len(tt.errors) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "app", cfg.Name)
+				return
+			}
+			if !assert.Error(t, err) {
+				return
+			}
+
+			var messages []string
+			var errs MultiError
+			if errors.As(err, &errs) {
+				for _, err := range errs {
+					messages = append(messages, err.Error())
+				}
+			} else {
+				messages = append(messages, err.Error())
+			}
+			for i, expected := range tt.errors {
+				tt.errors[i] = strings.Replace(expected, "{file}", filename, 1)
+			}
+			if tt.name == "violations" || tt.name == "root" || tt.name == "missing required" {
+				assert.Equal(t, tt.errors, messages)
+				assert.True(t, errors.Is(err, ErrSchema))
+				assert.Empty(t, cfg.Name, "file should not be decoded")
+				assert.NotContains(t, err.Error(), "s3cr3t")
+				return
+			}
+			for _, expected := range tt.errors {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaKeywords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		schema   string
+		data     string
+		messages []string
+	}{
+		{
+			name:     "boolean schema",
+			schema:   `{"properties": {"a": false, "b": true}}`,
+			data:     `{"a": 1, "b": 2}`,
+			messages: []string{"/a: value is not allowed"},
+		},
+		{
+			name:     "const and multipleOf",
+			schema:   `{"properties": {"a": {"const": 1.0}, "b": {"multipleOf": 0.5}}}`,
+			data:     `{"a": 1, "b": 0.75}`,
+			messages: []string{"/b: should be multiple of 0.5"},
+		},
+		{
+			name:     "prefixItems and contains",
+			schema:   `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}, "contains": {"type": "integer", "minimum": 10}}`,
+			data:     `["a", 1, "b"]`,
+			messages: []string{": should contain item matching schema", "/2: should be integer, got string"},
+		},
+		{
+			name:     "oneOf and not",
+			schema:   `{"oneOf": [{"minimum": 0}, {"maximum": 10}], "not": {"const": 5}}`,
+			data:     `5`,
+			messages: []string{": should match exactly one of schemas, matches 2", ": should not match schema"},
+		},
+		{
+			name: "if then else",
+			schema: `{"if": {"properties": {"tls": {"const": true}}}, "then": {"required": ["cert"]},
+				"else": {"properties": {"cert": false}}}`,
+			data:     `{"tls": true}`,
+			messages: []string{`: missing required property "cert"`},
+		},
+		{
+			name:     "dependentRequired and patternProperties",
+			schema:   `{"dependentRequired": {"user": ["password"]}, "patternProperties": {"^x-": {"type": "string"}}}`,
+			data:     `{"user": "admin", "x-id": 1, "a/b": 1}`,
+			messages: []string{`: missing property "password" required by "user"`, "/x-id: should be string, got integer"},
+		},
+		{
+			name:     "escaped pointer",
+			schema:   `{"additionalProperties": {"type": "string"}}`,
+			data:     `{"a/b~c": 1}`,
+			messages: []string{"/a~1b~0c: should be string, got integer"},
+		},
+		{
+			name:     "formats",
+			schema:   `{"items": {"anyOf": [{"format": "date-time"}, {"format": "email"}, {"format": "uri"}]}}`,
+			data:     `["2020-01-02T03:04:05Z", "user@example.com", "https://example.com", "example"]`,
+			messages: []string{"/3: should match any of schemas"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := decodeJSON([]byte(tt.schema))
+			if !assert.NoError(t, err) {
+				return
+			}
+			doc, err := decodeJSON([]byte(tt.data))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			s := &schemaValidator{root: root, patterns: make(map[string]*regexp.Regexp)}
+			violations, err := s.validate(root, doc, "")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var messages []string
+			for _, v := range violations {
+				messages = append(messages, v.pointer+": "+v.message)
+			}
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
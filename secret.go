@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// secretTag marks field, which value is redacted in errors and provenance
+	secretTag = "secret"
+	// redacted is shown instead of value of secret
+	redacted = "[REDACTED]"
+)
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// Secret is a string, which value is redacted, when it is printed by `fmt`, marshaled to JSON or YAML
+// and reported in errors. Value returns the actual value
+type Secret string
+
+// Value returns the actual value of secret
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String redacts secret for `fmt` verbs
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString redacts secret for `%#v`
+func (s Secret) GoString() string {
+	return "config.Secret(" + strconv.Quote(redacted) + ")"
+}
+
+// Format redacts secret for all `fmt` verbs, flags and width are kept
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, s.GoString())
+		return
+	}
+
+	format := "%"
+	for _, flag := range "+- 0" {
+		if f.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		format += strconv.Itoa(width)
+	}
+	if verb == 'q' {
+		format += "q"
+	} else {
+		format += "s"
+	}
+	_, _ = fmt.Fprintf(f, format, redacted)
+}
+
+// MarshalText redacts secret for JSON and YAML
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// isSecret reports whether value of field `t` of type `typ` is redacted,
+// composite value is redacted as a whole, when it contains secret
+func isSecret(t reflect.StructField, typ reflect.Type) bool {
+	return isSecretTag(t) || hasSecret(typ)
+}
+
+// isSecretTag reports whether field is tagged as secret, its nested fields are secrets as well
+func isSecretTag(t reflect.StructField) bool {
+	return isTrue(t.Tag.Get(secretTag))
+}
+
+// hasSecret reports whether type is `Secret` or contains secret fields
+func hasSecret(t reflect.Type) bool {
+	t = indirectType(t)
+	switch {
+	case t == secretType:
+		return true
+	case t.Kind() == reflect.Struct && !isTime(t):
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" && isSecret(f, f.Type) {
+				return true
+			}
+		}
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array, t.Kind() == reflect.Map:
+		return hasSecret(t.Elem())
+	}
+	return false
+}
+
+// isSecretJSONPath reports whether value stored under path of `json` names is redacted,
+// values nested in field tagged as secret are redacted as well
+func isSecretJSONPath(t reflect.Type, path string) bool {
+	if path == "" {
+		return false
+	}
+
+	var field reflect.StructField
+	for _, name := range strings.Split(path, ".") {
+		switch t = indirectType(t); t.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByJSONName(t, name)
+			if !ok || isTime(t) {
+				return false
+			}
+			if isSecretTag(f) {
+				return true
+			}
+			field, t = f, f.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return isSecret(field, t)
+}
+
+// redactFieldError hides value of secret field `t` of type `typ` in error,
+// `secret` is set for field nested in field tagged as secret
+func redactFieldError(t reflect.StructField, typ reflect.Type, secret bool, err *FieldError) *FieldError {
+	if !secret && !isSecret(t, typ) {
+		return err
+	}
+	err.Err = redactError(err.Err, err.Value)
+	err.Value = redacted
+	return err
+}
+
+// redactError hides value in message of error, quoted value is replaced as errors of parsing quote it
+func redactError(err error, value string) error {
+	if value == "" || value == redacted {
+		return err
+	}
+	return &redactedError{err: err, value: value}
+}
+
+// redactedError hides value in message of wrapped error, wrapped error is still matched by `errors.Is` and `errors.As`
+type redactedError struct {
+	err   error
+	value string
+}
+
+func (e *redactedError) Error() string {
+	return strings.Replace(e.err.Error(), strconv.Quote(e.value), strconv.Quote(redacted), -1)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+
+	secret := Secret("12345")
+	assert.Equal(t, "12345", secret.Value())
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		assert.NotContains(t, fmt.Sprintf(format, secret), "12345", format)
+		assert.NotContains(t, fmt.Sprintf(format, struct{ Password Secret }{secret}), "12345", format)
+		assert.NotContains(t, fmt.Sprintf(format, &struct{ Password *Secret }{&secret}), "12345", format)
+	}
+	assert.Equal(t, "{[REDACTED]}", fmt.Sprint(struct{ Password Secret }{secret}))
+	assert.Equal(t, "[REDACTED]  |", fmt.Sprintf("%-12s|", secret))
+
+	b, err := json.Marshal(map[string]interface{}{"password": secret, "passwords": []Secret{secret}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"password":"[REDACTED]","passwords":["[REDACTED]"]}`, string(b))
+
+	var decoded struct {
+		Password Secret `json:"password"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"password": "12345"}`), &decoded))
+	assert.Equal(t, "12345", decoded.Password.Value())
+}
+
+func TestInitSecretRedaction(t *testing.T) {
+	t.Parallel()
+
+	type Postgres struct {
+		Host     string `json:"host"     envconfig:"REDACT_POSTGRES_HOST"`
+		Port     int    `json:"port"     envconfig:"REDACT_POSTGRES_PORT"     secret:"true"`
+		Password Secret `json:"password" envconfig:"REDACT_POSTGRES_PASSWORD" validate:"min=8"`
+		Token    string `json:"token"    envconfig:"REDACT_POSTGRES_TOKEN"    secret:"true" validate:"oneof=a b"`
+	}
+
+	type Config struct {
+		Postgres Postgres   `json:"postgres" envconfig:"REDACT_POSTGRES"`
+		Replicas []Postgres `json:"replicas" envprefix:"REDACT_REPLICAS"`
+		Salt     int        `json:"salt"     secret:"true" default:"s3cr3t"`
+		DSN      string     `json:"dsn"      secret:"true"`
+		Creds    struct {
+			User string   `json:"user"`
+			Pass []string `json:"pass"`
+			Pin  int      `json:"pin"  envconfig:"REDACT_CREDS_PIN"`
+			Seed int      `json:"seed" default:"s3cr3t"`
+			Keys []struct {
+				ID int `json:"id"`
+			} `json:"keys" envprefix:"REDACT_CREDS_KEYS"`
+		} `json:"creds" secret:"true"`
+	}
+
+	dir, err := ioutil.TempDir("", "redact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretsDir := filepath.Join(dir, "secrets")
+	if err := os.Mkdir(secretsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(secretsDir, "creds.pin"), []byte("s3cr3t"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		env     MapEnv
+		opts    []Option
+		errors  []string
+	}{
+		{
+			name: "env",
+			env: testEnv(
+				"REDACT_POSTGRES_PORT", "s3cr3t",
+				"REDACT_POSTGRES_PASSWORD", "s3cr3t",
+				"REDACT_POSTGRES_TOKEN", "s3cr3t",
+				"REDACT_REPLICAS_0_PORT", "s3cr3t",
+			),
+			errors: []string{
+				`Postgres.Port: env REDACT_POSTGRES_PORT: failed to parse value "[REDACTED]" as Int64 type`,
+				`Replicas[0].Port: env REDACT_REPLICAS_0_PORT: failed to parse value "[REDACTED]" as Int64 type`,
+				`Salt: default: failed to parse value "[REDACTED]" as Int64 type`,
+				`Postgres.Password: validate min=8: invalid value [REDACTED]`,
+				`Postgres.Token: validate oneof=a b: invalid value [REDACTED]`,
+			},
+		},
+		{
+			name: "JSON env of struct with secrets",
+			env:  testEnv("REDACT_POSTGRES", `{"password": "s3cr3t"`),
+			errors: []string{
+				`Postgres: env REDACT_POSTGRES: failed to parse value "[REDACTED]" as JSON of config.Postgres type: unexpected end of JSON input`,
+			},
+		},
+		{
+			name:    "file",
+			content: "{\n  \"postgres\": {\"token\": \"s3cr3t\", \"port\": \"s3cr3t\"}\n}",
+			errors: []string{
+				"postgres.port: file " + filepath.Join(dir, "file.json") +
+					":2:43: json: cannot unmarshal string into Go struct field",
+			},
+		},
+		{
+			name:    "syntax on secret line",
+			content: "{\n  \"postgres\": {\n    \"host\": \"localhost\",\n    \"password\": \"s3cr3t\n  }\n}",
+			errors: []string{
+				"postgres.password: file " + filepath.Join(dir, "syntax on secret line.json") +
+					":4:24: invalid character '\\n' in string",
+			},
+		},
+		{
+			name:    "syntax before secret on the same line",
+			content: `{"postgres": {"host": localhost, "password": "s3cr3t"}}`,
+			errors: []string{
+				"postgres.host: file " + filepath.Join(dir, "syntax before secret on the same line.json") +
+					":1:23: invalid character 'l' looking for beginning of value",
+			},
+		},
+		{
+			name:    "mistyped key of secret",
+			content: "{\n  \"postgres\": {\n    \"pasword\": \"s3cr3t\"\n  }\n}",
+			opts:    []Option{WithDisallowUnknownFields()},
+			errors: []string{
+				"postgres.pasword: file " + filepath.Join(dir, "mistyped key of secret.json") +
+					":3:5: unknown key, did you mean password?",
+			},
+		},
+		{
+			name:    "file of field nested in secret",
+			content: `{"creds": {"user": "admin", "pass": "s3cr3t"}}`,
+			errors: []string{
+				"creds.pass: file " + filepath.Join(dir, "file of field nested in secret.json") +
+					":1:37: json: cannot unmarshal string into Go struct field",
+			},
+		},
+		{
+			name: "env of fields nested in secret",
+			env: testEnv(
+				"REDACT_CREDS_PIN", "s3cr3t",
+				"REDACT_CREDS_KEYS_0_ID", "s3cr3t",
+			),
+			errors: []string{
+				`Creds.Pin: env REDACT_CREDS_PIN: failed to parse value "[REDACTED]" as Int64 type`,
+				`Creds.Keys[0].ID: env REDACT_CREDS_KEYS_0_ID: failed to parse value "[REDACTED]" as Int64 type`,
+				`Creds.Seed: default: failed to parse value "[REDACTED]" as Int64 type`,
+			},
+		},
+		{
+			name: "secrets dir of field nested in secret",
+			opts: []Option{WithSecretsDir(secretsDir)},
+			errors: []string{
+				"Creds.Pin: secret " + filepath.Join(secretsDir, "creds.pin") + `: failed to parse value "[REDACTED]" as Int64 type`,
+			},
+		},
+		{
+			name:    "expand",
+			content: `{"dsn": "postgres://user:s3cr3t@${HOST"}`,
+			opts:    []Option{WithExpandVariables()},
+			errors:  []string{`expand dsn: unclosed reference in "[REDACTED]"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := ""
+			if tt.content != "" {
+				filename = filepath.Join(dir, tt.name+".json")
+				if err := ioutil.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.env == nil {
+				tt.env = MapEnv{}
+			}
+
+			var cfg Config
+			err := Init(&cfg, filename, append(tt.opts, WithEnv(tt.env))...)
+			if !assert.Error(t, err) {
+				return
+			}
+			assert.NotContains(t, err.Error(), "s3cr3t")
+
+			var errs MultiError
+			if errors.As(err, &errs) {
+				for _, err := range errs {
+					var fieldErr *FieldError
+					if errors.As(err, &fieldErr) {
+						assert.NotContains(t, fieldErr.Value, "s3cr3t")
+					}
+				}
+			}
+			for _, expected := range tt.errors {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+
+	t.Run("errors.Is", func(t *testing.T) {
+		var cfg Config
+		err := Init(&cfg, "", WithEnv(testEnv("REDACT_POSTGRES_PASSWORD", "s3cr3t")))
+		assert.True(t, errors.Is(err, ErrInvalid))
+	})
+}
+
+func TestProvenanceSecretRedaction(t *testing.T) {
+	t.Parallel()
+
+	var (
+		cfg struct {
+			Postgres struct {
+				Host     string   `envconfig:"PROVENANCE_SECRET_HOST"`
+				Password Secret   `envconfig:"PROVENANCE_SECRET_PASSWORD"`
+				Token    string   `envconfig:"PROVENANCE_SECRET_TOKEN" secret:"true"`
+				Keys     []string `envconfig:"PROVENANCE_SECRET_KEYS"  secret:"true"`
+			}
+			Creds struct {
+				User  string            `envconfig:"PROVENANCE_SECRET_USER"`
+				Pools map[string]string `envconfig:"PROVENANCE_SECRET_POOLS"`
+			} `secret:"true"`
+		}
+		p Provenance
+	)
+
+	err := Init(&cfg, "", WithProvenance(&p), WithEnv(testEnv(
+		"PROVENANCE_SECRET_HOST", "localhost",
+		"PROVENANCE_SECRET_PASSWORD", "s3cr3t",
+		"PROVENANCE_SECRET_TOKEN", "s3cr3t",
+		"PROVENANCE_SECRET_KEYS", "s3cr3t,s3cr3t",
+		"PROVENANCE_SECRET_USER", "s3cr3t",
+		"PROVENANCE_SECRET_POOLS", `{"orders": "s3cr3t"}`,
+	)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		`Postgres.Host = "localhost" (env PROVENANCE_SECRET_HOST)`,
+		`Postgres.Password = [REDACTED] (env PROVENANCE_SECRET_PASSWORD)`,
+		`Postgres.Token = [REDACTED] (env PROVENANCE_SECRET_TOKEN)`,
+		`Postgres.Keys = [REDACTED] (env PROVENANCE_SECRET_KEYS)`,
+		`Creds.User = [REDACTED] (env PROVENANCE_SECRET_USER)`,
+		`Creds.Pools = [REDACTED] (env PROVENANCE_SECRET_POOLS)`,
+		``,
+	}, "\n"), p.String())
+
+	for _, path := range []string{"Postgres.Token", "Creds.User", "Creds.Pools[orders]"} {
+		origin, _ := p.Explain(path)
+		assert.Equal(t, redacted, origin.Value, path)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// kubernetesDataDir is a symlink to the current version of Kubernetes volume, keys are symlinks into it
+const kubernetesDataDir = "..data"
+
+// applySecretsDir applies values of secrets directory to fields by `envconfig` names or by path of `json` names,
+// e.g. file `POSTGRES_PASSWORD` or `postgres.password`
+func (l *loader) applySecretsDir(v reflect.Value) error {
+	secrets, err := readSecretsDir(l.secretsDir)
+	if err != nil {
+		return err
+	}
+
+	return walkFields("", "", reflect.StructField{}, v, false, func(path, jsonPath string, t reflect.StructField, v reflect.Value, secret bool) error {
+		for _, name := range append(envNames(t), jsonPath) {
+			value, ok := secrets[name]
+			if !ok {
+				continue
+			}
+			if err := setEnvValue(t, v, value); err != nil {
+				return redactFieldError(t, v.Type(), secret, &FieldError{Path: path, Source: SourceSecret, Key: filepath.Join(l.secretsDir, name), Value: value, Err: err})
+			}
+			l.record(path, SourceSecret, filepath.Join(l.secretsDir, name))
+			return nil
+		}
+		return nil
+	})
+}
+
+// readSecretsDir reads files of directory, hidden files are skipped.
+// Kubernetes volume is read from its current version, so all values come from the same update
+func readSecretsDir(dir string) (map[string]string, error) {
+	if data, err := filepath.EvalSymlinks(filepath.Join(dir, kubernetesDataDir)); err == nil {
+		dir = data
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read secrets dir: %s", err)
+	}
+
+	secrets := make(map[string]string, len(files))
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		filename := filepath.Join(dir, file.Name())
+
+		// keys of Kubernetes volume are symlinks
+		info, err := os.Stat(filename)
+		if err != nil {
+			return nil, fmt.Errorf("read secret: %s", err)
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("read secret: %s", err)
+		}
+		secrets[file.Name()] = strings.TrimRight(string(b), "\r\n")
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitWithSecretsDir(t *testing.T) {
+	t.Parallel()
+
+	type Postgres struct {
+		Host     string `json:"host"     envconfig:"SECRETS_POSTGRES_HOST"`
+		User     string `json:"user"     envconfig:"SECRETS_POSTGRES_USER"`
+		Password string `json:"password" envconfig:"SECRETS_POSTGRES_PASSWORD"`
+	}
+
+	type Config struct {
+		Postgres Postgres `json:"postgres"`
+	}
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// plain directory, e.g. Docker secrets
+	plain := filepath.Join(dir, "plain")
+	helperWriteFiles(t, plain, map[string]string{
+		"SECRETS_POSTGRES_PASSWORD": "secret\n",
+		"postgres.user":             "user",
+		".hidden":                   "hidden",
+	})
+
+	// Kubernetes volume
+	volume := filepath.Join(dir, "volume")
+	helperWriteFiles(t, filepath.Join(volume, "..2026_10_18_00_00_00.1"), map[string]string{
+		"SECRETS_POSTGRES_PASSWORD": "k8s-secret\n",
+		"SECRETS_POSTGRES_HOST":     "k8s-host",
+	})
+	helperSymlink(t, "..2026_10_18_00_00_00.1", filepath.Join(volume, kubernetesDataDir))
+	helperSymlink(t, filepath.Join(kubernetesDataDir, "SECRETS_POSTGRES_PASSWORD"), filepath.Join(volume, "SECRETS_POSTGRES_PASSWORD"))
+	helperSymlink(t, filepath.Join(kubernetesDataDir, "SECRETS_POSTGRES_HOST"), filepath.Join(volume, "SECRETS_POSTGRES_HOST"))
+
+	tests := []struct {
+		name   string
+		dir    string
+		envs   []string
+		expect Postgres
+		error  string
+	}{
+		{
+			name:   "plain directory",
+			dir:    plain,
+			expect: Postgres{Host: "localhost", User: "user", Password: "secret"},
+		},
+		{
+			name:   "kubernetes volume",
+			dir:    volume,
+			expect: Postgres{Host: "k8s-host", User: "postgres", Password: "k8s-secret"},
+		},
+		{
+			name:   "env overrides secrets",
+			dir:    plain,
+			envs:   []string{"SECRETS_POSTGRES_PASSWORD", "env-secret"},
+			expect: Postgres{Host: "localhost", User: "user", Password: "env-secret"},
+		},
+		{
+			name:  "missing directory",
+			dir:   filepath.Join(dir, "missing"),
+			error: "read secrets dir: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := Init(&cfg, "testdata/config.json", WithEnv(testEnv(tt.envs...)), WithSecretsDir(tt.dir))
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, cfg.Postgres)
+		})
+	}
+}
+
+func helperWriteFiles(t *testing.T, dir string, files map[string]string) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func helperSymlink(t *testing.T, target, link string) {
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownEnv is reported for ENV, which looks like ENV of config, but matches no field
+var ErrUnknownEnv = errors.New("matches no field")
+
+var sliceElementKey = regexp.MustCompile(`^\d+_(\w+)$`)
+
+// envPrefix is `envprefix` of slice or map of structs
+type envPrefix struct {
+	prefix string
+	elemOf reflect.Type
+	slice  bool
+}
+
+// checkStrayEnv reports ENV, which starts with one of prefixes passed by option or with `envprefix`, but matches no field.
+// ENV is stray, if it was not applied and does not address any field, e.g. fallback name shadowed by the first one is not stray
+func (l *loader) checkStrayEnv(t reflect.Type) error {
+	names, prefixes := envKeysOf(t)
+
+	var errs MultiError
+
+	for _, keyVal := range l.env.Environ() {
+		i := strings.Index(keyVal, "=")
+		if i < 0 {
+			continue
+		}
+		key := keyVal[:i]
+		if l.usedEnv[key] || containsString(names, strings.TrimSuffix(key, fileEnvSuffix)) {
+			continue
+		}
+
+		suggestion, stray := "", false
+		if p, ok := prefixOf(key, prefixes); ok {
+			suggestion, stray = p.strayElementKey(strings.TrimPrefix(key, p.prefix+"_"))
+		} else if hasAnyPrefix(key, l.strayEnvPrefixes) {
+			suggestion, _ = closestName(key, names)
+			stray = true
+		}
+		if !stray {
+			continue
+		}
+
+		err := ErrUnknownEnv
+		if suggestion != "" {
+			err = fmt.Errorf("%w, did you mean %s?", ErrUnknownEnv, suggestion)
+		}
+		// value is not reported, as mistyped ENV might hold a secret
+		fieldErr := &FieldError{Source: SourceEnv, Key: key, Err: err}
+
+		if !l.strayEnvErrors {
+			l.warn("%s", fieldErr)
+			continue
+		}
+		errs = errs.append(fieldErr)
+	}
+
+	return errs.err()
+}
+
+// strayElementKey reports whether `key` relative to prefix addresses no field of element,
+// the closest ENV of element is returned as suggestion
+func (p envPrefix) strayElementKey(key string) (string, bool) {
+	if p.slice {
+		if key == sliceLenKey {
+			return "", false
+		}
+		matches := sliceElementKey.FindStringSubmatch(key)
+		if matches == nil {
+			return "", true
+		}
+		field := matches[1]
+		if field == sliceDeleteKey || hasElementKey(p.elemOf, field) {
+			return "", false
+		}
+		return p.suggest(key[:len(key)-len(field)], field), true
+	}
+
+	for i, r := range key {
+		if r == '_' && i > 0 && hasElementKey(p.elemOf, key[i+1:]) {
+			return "", false
+		}
+	}
+
+	// key of map is taken till the first underscore
+	i := strings.Index(key, "_")
+	if i < 0 {
+		return "", true
+	}
+	return p.suggest(key[:i+1], key[i+1:]), true
+}
+
+// suggest returns ENV of element, which is the closest to `field`, `element` is prefix of element.
+// Field itself is never suggested
+func (p envPrefix) suggest(element, field string) string {
+	var keys []string
+	for _, key := range elementEnvKeys(p.elemOf) {
+		if key != field {
+			keys = append(keys, key)
+		}
+	}
+	if closest, ok := closestName(field, keys); ok {
+		return p.prefix + "_" + element + closest
+	}
+	return ""
+}
+
+// envKeysOf returns ENV names of fields of struct `t` and `envprefix` of its slices and maps, elements are not walked
+func envKeysOf(t reflect.Type) (names []string, prefixes []envPrefix) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		names = append(names, envNames(f)...)
+
+		if prefix, ok := f.Tag.Lookup(envPrefixTag); ok {
+			switch ft := indirectType(f.Type); ft.Kind() {
+			case reflect.Slice, reflect.Map:
+				prefixes = append(prefixes, envPrefix{prefix: prefix, elemOf: indirectType(ft.Elem()), slice: ft.Kind() == reflect.Slice})
+				continue
+			}
+		}
+
+		if f.Type.Kind() == reflect.Struct && !isTime(f.Type) {
+			nestedNames, nestedPrefixes := envKeysOf(f.Type)
+			names = append(names, nestedNames...)
+			prefixes = append(prefixes, nestedPrefixes...)
+		}
+	}
+	return names, prefixes
+}
+
+// elementEnvKeys returns ENV keys of fields of element relative to element prefix, nested slices and maps are skipped
+func elementEnvKeys(t reflect.Type) (keys []string) {
+	if t.Kind() != reflect.Struct || isTime(t) {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if _, ok := f.Tag.Lookup(envPrefixTag); ok {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct && !isTime(f.Type) {
+			for _, key := range elementEnvKeys(f.Type) {
+				keys = append(keys, toEnvKey(f.Name)+"_"+key)
+			}
+			continue
+		}
+		keys = append(keys, envNames(f)...)
+		if name := toEnvKey(f.Name); !containsString(keys, name) {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// prefixOf returns `envprefix`, which `key` starts with, the longest one wins
+func prefixOf(key string, prefixes []envPrefix) (envPrefix, bool) {
+	var (
+		longest envPrefix
+		found   bool
+	)
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p.prefix+"_") && len(p.prefix) > len(longest.prefix) {
+			longest, found = p, true
+		}
+	}
+	return longest, found
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
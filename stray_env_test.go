@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitStrayEnv(t *testing.T) {
+	t.Parallel()
+
+	type Replica struct {
+		Host string `envconfig:"POSTGRES_HOST"`
+		TLS  struct {
+			CAFile string
+		}
+	}
+
+	type Config struct {
+		Redis struct {
+			Addrs []string `envconfig:"STRAY_REDIS_ADDR,STRAY_REDIS_ADDRS"`
+		}
+		Password string             `envconfig:"STRAY_PASSWORD"`
+		Replicas []Replica          `envprefix:"STRAY_REPLICAS"`
+		Pools    map[string]Replica `envprefix:"STRAY_POOLS"`
+	}
+
+	env := testEnv(
+		"STRAY_REDIS_ADRR", "localhost:6379",
+		"STRAY_REDIS_ADDR", "localhost:6379",
+		"STRAY_REDIS_ADDRS", "localhost:6380",
+		"STRAY_PASSWORD_FILE", "/dev/null",
+		"STRAY_REPLICAS_0_HOST", "replica0",
+		"STRAY_REPLICAS_LEN", "1",
+		"STRAY_REPLICAS_X_HOST", "replica",
+		"STRAY_POOLS_ORDERS_TLS_CA_FILE", "/etc/ssl/ca.pem",
+		"STRAY_POOLS_USERS_TSL_CA_FILE", "/etc/ssl/ca.pem",
+		"STRAY_UNKNOWN", "1",
+		"OTHER_REDIS_ADRR", "localhost:6379",
+	)
+
+	t.Run("warnings", func(t *testing.T) {
+		var (
+			cfg      Config
+			warnings []string
+		)
+		err := Init(&cfg, "", WithEnv(env), WithStrayEnvWarnings("STRAY_"), WithWarningHook(func(message string) {
+			warnings = append(warnings, message)
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"env STRAY_POOLS_USERS_TSL_CA_FILE: matches no field, did you mean STRAY_POOLS_USERS_TLS_CA_FILE?",
+			"env STRAY_REDIS_ADRR: matches no field, did you mean STRAY_REDIS_ADDR?",
+			"env STRAY_REPLICAS_X_HOST: matches no field",
+			"env STRAY_UNKNOWN: matches no field",
+		}, warnings)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var cfg Config
+		err := Init(&cfg, "", WithEnv(env), WithStrayEnvErrors())
+		assert.True(t, errors.Is(err, ErrUnknownEnv))
+
+		var errs MultiError
+		if assert.True(t, errors.As(err, &errs)) && assert.Len(t, errs, 2) {
+			assert.Equal(t, &FieldError{Source: SourceEnv, Key: "STRAY_REPLICAS_X_HOST", Err: ErrUnknownEnv}, errs[1])
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var cfg Config
+		assert.NoError(t, Init(&cfg, "", WithEnv(env)))
+	})
+
+	t.Run("unknown field of slice element", func(t *testing.T) {
+		var cfg Config
+		err := Init(&cfg, "", WithEnv(testEnv("STRAY_REPLICAS_0_POSTGRES_HSOT", "replica0")))
+		assert.EqualError(t, err, "Replicas[0]: env STRAY_REPLICAS_0_POSTGRES_HSOT: field PostgresHsot not found, "+
+			"did you mean STRAY_REPLICAS_0_POSTGRES_HOST?")
+	})
+
+	t.Run("unknown field of map element", func(t *testing.T) {
+		var cfg Config
+		err := Init(&cfg, "", WithEnv(testEnv(
+			"STRAY_POOLS_ORDERS_TLS_CA_FILE", "/etc/ssl/ca.pem",
+			"STRAY_POOLS_ORDERS_TSL_CA_FILE", "/etc/ssl/ca.pem",
+		)))
+		assert.EqualError(t, err, "Pools[orders]: env STRAY_POOLS_ORDERS_TSL_CA_FILE: field TslCaFile not found, "+
+			"did you mean STRAY_POOLS_ORDERS_TLS_CA_FILE?")
+	})
+	t.Run("suggestion is not the reported key", func(t *testing.T) {
+		type Replica struct {
+			Host string `envconfig:"HOST"`
+		}
+		p := envPrefix{prefix: "STRAY_REPLICAS", elemOf: reflect.TypeOf(Replica{}), slice: true}
+		assert.Equal(t, "", p.suggest("0_", "HOST"))
+		assert.Equal(t, "STRAY_REPLICAS_0_HOST", p.suggest("0_", "HSOT"))
+	})
+}
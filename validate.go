@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	requiredTag = "required"
+	validateTag = "validate"
+)
+
+var errRuleNotSupported = errors.New("rule is not supported by type")
+
+// ruleFunc checks value against argument of rule, error is returned when rule could not be checked
+type ruleFunc func(v reflect.Value, arg string) (bool, error)
+
+// rules of `validate` tag, e.g. `validate:"min=1,max=10"`.
+// Argument containing comma should escape it by backslash, e.g. `validate:"regexp=^a{1\\,3}$"`
+var rules = map[string]ruleFunc{
+	"min":         compareRule(func(value, limit float64) bool { return value >= limit }),
+	"max":         compareRule(func(value, limit float64) bool { return value <= limit }),
+	"oneof":       oneOf,
+	"regexp":      matchRegexp,
+	"url":         stringRule(isURL),
+	"hostport":    stringRule(isHostPort),
+	"ip":          stringRule(func(s string) bool { return net.ParseIP(s) != nil }),
+	"cidr":        stringRule(func(s string) bool { _, _, err := net.ParseCIDR(s); return err == nil }),
+	"email":       stringRule(isEmail),
+	"file_exists": stringRule(func(s string) bool { info, err := os.Stat(s); return err == nil && !info.IsDir() }),
+	"dir_exists":  stringRule(func(s string) bool { info, err := os.Stat(s); return err == nil && info.IsDir() }),
+}
+
+// fieldRuleFunc checks value against other fields of enclosing struct `parent`
+type fieldRuleFunc func(parent, v reflect.Value, arg string) (bool, error)
+
+// fieldRules refer other fields of enclosing struct by field or `json` names, e.g. `validate:"required_if=Enabled true"`
+var fieldRules = map[string]fieldRuleFunc{
+	"required_if":   requiredIf,
+	"required_with": requiredWith,
+	"excluded_with": excludedWith,
+	"ltfield":       compareFieldRule(func(order int) bool { return order < 0 }),
+	"ltefield":      compareFieldRule(func(order int) bool { return order <= 0 }),
+	"gtfield":       compareFieldRule(func(order int) bool { return order > 0 }),
+	"gtefield":      compareFieldRule(func(order int) bool { return order >= 0 }),
+}
+
+// checkRules checks value stored under `path` against rules of `validate` tag, `parent` is enclosing struct of value
+func checkRules(parent reflect.Value, path string, t reflect.StructField, v reflect.Value) (errs MultiError) {
+	tag, ok := t.Tag.Lookup(validateTag)
+	if !ok {
+		return nil
+	}
+
+	for _, rule := range (listFormat{sep: ",", trim: true, skipEmpty: true}).split(tag) {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		var (
+			valid bool
+			err   error
+		)
+
+		if check, ok := rules[name]; ok {
+			valid, err = check(reflect.Indirect(v), arg)
+		} else if check, ok := fieldRules[name]; ok {
+			valid, err = check(parent, v, arg)
+		} else {
+			errs = errs.append(&FieldError{Path: path, Source: SourceValidate, Key: rule, Err: errors.New("unknown rule")})
+			continue
+		}
+
+		value, formatted := fmt.Sprint(valueOf(v)), formatValue(valueOf(v))
+		if isSecret(t, v.Type()) {
+			value, formatted = redacted, redacted
+		}
+		if err == nil && !valid {
+			err = fmt.Errorf("%w %s", ErrInvalid, formatted)
+		}
+		if err != nil {
+			errs = errs.append(&FieldError{Path: path, Source: SourceValidate, Key: rule, Value: value, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// compareRule compares number, duration or length of value with argument
+func compareRule(cmp func(value, limit float64) bool) ruleFunc {
+	return func(v reflect.Value, arg string) (bool, error) {
+		if !v.IsValid() {
+			return true, nil
+		}
+		return compare(v, arg, cmp)
+	}
+}
+
+func compare(v reflect.Value, arg string, cmp func(value, limit float64) bool) (bool, error) {
+	switch v.Type() {
+	case durationType, durationCustomType:
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return false, err
+		}
+		return cmp(float64(v.Int()), float64(d)), nil
+	}
+
+	var value float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		value = float64(v.Len())
+	default:
+		return false, errRuleNotSupported
+	}
+
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return false, err
+	}
+	return cmp(value, limit), nil
+}
+
+func oneOf(v reflect.Value, arg string) (bool, error) {
+	options := strings.Fields(arg)
+	if v.Kind() == reflect.String || v.Kind() == reflect.Slice {
+		return stringRule(func(s string) bool { return containsString(options, s) })(v, arg)
+	}
+	if !v.IsValid() {
+		return true, nil
+	}
+	return containsString(options, fmt.Sprint(v.Interface())), nil
+}
+
+func matchRegexp(v reflect.Value, arg string) (bool, error) {
+	re, err := regexp.Compile(arg)
+	if err != nil {
+		return false, err
+	}
+	return stringRule(re.MatchString)(v, arg)
+}
+
+// stringRule checks string or every element of slice of strings, empty strings are skipped
+func stringRule(fn func(s string) bool) ruleFunc {
+	return func(v reflect.Value, _ string) (bool, error) {
+		switch {
+		case !v.IsValid():
+			return true, nil
+		case v.Kind() == reflect.String:
+			return v.Len() == 0 || fn(v.String()), nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < v.Len(); i++ {
+				if s := v.Index(i).String(); s != "" && !fn(s) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+		return false, errRuleNotSupported
+	}
+}
+
+// requiredIf requires value, when all listed fields have listed values, e.g. `required_if=Enabled true Mode tls`
+func requiredIf(parent, v reflect.Value, arg string) (bool, error) {
+	args := strings.Fields(arg)
+	if len(args) == 0 || len(args)%2 != 0 {
+		return false, errors.New("field and value pairs are expected")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		f, err := siblingField(parent, args[i])
+		if err != nil {
+			return false, err
+		}
+		if fmt.Sprint(valueOf(f)) != args[i+1] {
+			return true, nil
+		}
+	}
+
+	return !isZero(v), nil
+}
+
+// requiredWith requires value, when any of listed fields is not empty
+func requiredWith(parent, v reflect.Value, arg string) (bool, error) {
+	present, err := anyPresent(parent, arg)
+	return !present || !isZero(v), err
+}
+
+// excludedWith requires empty value, when any of listed fields is not empty
+func excludedWith(parent, v reflect.Value, arg string) (bool, error) {
+	present, err := anyPresent(parent, arg)
+	return !present || isZero(v), err
+}
+
+func anyPresent(parent reflect.Value, arg string) (bool, error) {
+	for _, name := range strings.Fields(arg) {
+		f, err := siblingField(parent, name)
+		if err != nil {
+			return false, err
+		}
+		if !isZero(f) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compareFieldRule compares value with other field, both should be numbers, durations or times
+func compareFieldRule(cmp func(order int) bool) fieldRuleFunc {
+	return func(parent, v reflect.Value, arg string) (bool, error) {
+		f, err := siblingField(parent, arg)
+		if err != nil {
+			return false, err
+		}
+
+		a, b := reflect.Indirect(v), reflect.Indirect(f)
+		if !a.IsValid() || !b.IsValid() {
+			return true, nil
+		}
+
+		order, err := orderOf(a, b)
+		if err != nil {
+			return false, err
+		}
+		return cmp(order), nil
+	}
+}
+
+// orderOf returns -1, 0 or 1, when `a` is less, equal or greater than `b`
+func orderOf(a, b reflect.Value) (int, error) {
+	if a.Type() != b.Type() {
+		return 0, fmt.Errorf("fields of %s and %s types could not be compared", a.Type(), b.Type())
+	}
+
+	if a.Type() == timeType {
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	var x, y float64
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y = float64(a.Int()), float64(b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, y = float64(a.Uint()), float64(b.Uint())
+	case reflect.Float32, reflect.Float64:
+		x, y = a.Float(), b.Float()
+	default:
+		return 0, errRuleNotSupported
+	}
+
+	switch {
+	case x < y:
+		return -1, nil
+	case x > y:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// siblingField looks up field of enclosing struct by field or `json` name, nested fields are separated by dot
+func siblingField(parent reflect.Value, name string) (reflect.Value, error) {
+	if !parent.IsValid() {
+		return reflect.Value{}, fmt.Errorf("field %s is not found, there is no enclosing struct", name)
+	}
+	f, _, ok := fieldByPath(parent, name)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("field %s is not found", name)
+	}
+	return f, nil
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func isHostPort(s string) bool {
+	_, port, err := net.SplitHostPort(s)
+	return err == nil && port != ""
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// valueOf returns value of field to report
+func valueOf(v reflect.Value) interface{} {
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == durationCustomType {
+		return time.Duration(v.Int())
+	}
+	return v.Interface()
+}
+
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRules(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		Port      int           `validate:"min=1,max=65535"`
+		Ratio     float64       `validate:"min=0.5"`
+		Timeout   time.Duration `validate:"min=1s,max=1m"`
+		Interval  Duration      `validate:"max=1m"`
+		Name      string        `validate:"min=3"`
+		Level     string        `validate:"oneof=debug info error"`
+		Retries   int           `validate:"oneof=1 3 5"`
+		Tags      []string      `validate:"max=2,regexp=^[a-z]{1\\,3}$"`
+		URL       string        `validate:"url"`
+		Addr      string        `validate:"hostport"`
+		IP        string        `validate:"ip"`
+		CIDR      string        `validate:"cidr"`
+		Email     string        `validate:"email"`
+		File      string        `validate:"file_exists"`
+		Dir       string        `validate:"dir_exists"`
+		Empty     string        `validate:"url,email"`
+		Unknown   string        `validate:"unknown"`
+		BadArg    int           `validate:"min=one"`
+		BadRegexp string        `validate:"regexp=("`
+		Bool      bool          `validate:"min=1"`
+	}
+
+	valid := test{
+		Port:     8080,
+		Ratio:    0.5,
+		Timeout:  time.Second,
+		Interval: Duration(time.Minute),
+		Name:     "api",
+		Level:    "info",
+		Retries:  3,
+		Tags:     []string{"a", "abc"},
+		URL:      "https://example.com/path",
+		Addr:     "localhost:8080",
+		IP:       "10.0.0.1",
+		CIDR:     "10.0.0.0/8",
+		Email:    "ops@example.com",
+		File:     "validate.go",
+		Dir:      "testdata",
+	}
+
+	invalid := test{
+		Port:     0,
+		Ratio:    0.1,
+		Timeout:  2 * time.Minute,
+		Interval: Duration(time.Hour),
+		Name:     "a",
+		Level:    "trace",
+		Retries:  2,
+		Tags:     []string{"a", "b", "abcd"},
+		URL:      "example.com",
+		Addr:     "localhost",
+		IP:       "10.0.0",
+		CIDR:     "10.0.0.0",
+		Email:    "ops",
+		File:     "testdata",
+		Dir:      "validate.go",
+	}
+
+	tests := []struct {
+		name   string
+		value  test
+		expect map[string][]string
+	}{
+		{
+			name:  "valid",
+			value: valid,
+			expect: map[string][]string{
+				"Unknown":   {"Unknown: validate unknown: unknown rule"},
+				"BadArg":    {`BadArg: validate min=one: strconv.ParseFloat: parsing "one": invalid syntax`},
+				"BadRegexp": {"BadRegexp: validate regexp=(: error parsing regexp: missing closing ): `(`"},
+				"Bool":      {"Bool: validate min=1: rule is not supported by type"},
+			},
+		},
+		{
+			name:  "invalid",
+			value: invalid,
+			expect: map[string][]string{
+				"Port":      {"Port: validate min=1: invalid value 0"},
+				"Ratio":     {"Ratio: validate min=0.5: invalid value 0.1"},
+				"Timeout":   {"Timeout: validate max=1m: invalid value 2m0s"},
+				"Interval":  {"Interval: validate max=1m: invalid value 1h0m0s"},
+				"Name":      {`Name: validate min=3: invalid value "a"`},
+				"Level":     {`Level: validate oneof=debug info error: invalid value "trace"`},
+				"Retries":   {"Retries: validate oneof=1 3 5: invalid value 2"},
+				"Tags":      {"Tags: validate max=2: invalid value [a b abcd]", "Tags: validate regexp=^[a-z]{1,3}$: invalid value [a b abcd]"},
+				"URL":       {`URL: validate url: invalid value "example.com"`},
+				"Addr":      {`Addr: validate hostport: invalid value "localhost"`},
+				"IP":        {`IP: validate ip: invalid value "10.0.0"`},
+				"CIDR":      {`CIDR: validate cidr: invalid value "10.0.0.0"`},
+				"Email":     {`Email: validate email: invalid value "ops"`},
+				"File":      {`File: validate file_exists: invalid value "testdata"`},
+				"Dir":       {`Dir: validate dir_exists: invalid value "validate.go"`},
+				"Unknown":   {"Unknown: validate unknown: unknown rule"},
+				"BadArg":    {`BadArg: validate min=one: strconv.ParseFloat: parsing "one": invalid syntax`},
+				"BadRegexp": {"BadRegexp: validate regexp=(: error parsing regexp: missing closing ): `(`"},
+				"Bool":      {"Bool: validate min=1: rule is not supported by type"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := reflect.ValueOf(tt.value)
+			actual := make(map[string][]string)
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Type().Field(i)
+				for _, err := range checkRules(v, f.Name, f, v.Field(i)) {
+					actual[f.Name] = append(actual[f.Name], err.Error())
+				}
+			}
+			assert.Equal(t, tt.expect, actual)
+		})
+	}
+}
+
+func TestInitValidateRules(t *testing.T) {
+	t.Parallel()
+
+	var cfg struct {
+		Host string `envconfig:"HOST" required:"true"`
+		Port int    `envconfig:"PORT" default:"8080" validate:"min=1,max=65535"`
+		Mode string `envconfig:"MODE" default:"debug" validate:"oneof=debug release"`
+	}
+
+	err := Init(&cfg, "", WithEnv(MapEnv{"PORT": "0", "MODE": "test"}))
+	assert.EqualError(t, err, `Host: validate required: field is not filled up; `+
+		`Port: validate min=1: invalid value 0; Mode: validate oneof=debug release: invalid value "test"`)
+}
+
+func TestCheckFieldRules(t *testing.T) {
+	t.Parallel()
+
+	type TLS struct {
+		Enabled  bool   `json:"enabled"`
+		CertFile string `json:"cert_file" validate:"required_if=Enabled true"`
+		KeyFile  string `json:"key_file"  validate:"required_with=cert_file"`
+		Insecure bool   `json:"insecure"  validate:"excluded_with=CertFile KeyFile"`
+	}
+
+	type Server struct {
+		ReadTimeout  time.Duration `validate:"ltfield=WriteTimeout"`
+		WriteTimeout time.Duration `validate:"gtefield=ReadTimeout"`
+		MinConns     int           `validate:"ltefield=Limits.MaxConns"`
+		Limits       struct {
+			MaxConns int
+		}
+		TLS     TLS
+		Unknown string `validate:"required_with=Missing"`
+		BadPair string `validate:"required_if=Enabled"`
+		BadType string `validate:"ltfield=MinConns"`
+	}
+
+	tests := []struct {
+		name   string
+		value  Server
+		expect []string
+	}{
+		{
+			name: "valid",
+			value: Server{
+				ReadTimeout:  time.Second,
+				WriteTimeout: 2 * time.Second,
+				MinConns:     1,
+				Limits:       struct{ MaxConns int }{MaxConns: 1},
+				TLS:          TLS{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem"},
+			},
+			expect: []string{
+				"Unknown: validate required_with=Missing: field Missing is not found",
+				"BadPair: validate required_if=Enabled: field and value pairs are expected",
+				"BadType: validate ltfield=MinConns: fields of string and int types could not be compared",
+			},
+		},
+		{
+			name: "invalid",
+			value: Server{
+				ReadTimeout:  2 * time.Second,
+				WriteTimeout: time.Second,
+				MinConns:     2,
+				Limits:       struct{ MaxConns int }{MaxConns: 1},
+				TLS:          TLS{Enabled: true, Insecure: true, KeyFile: "key.pem"},
+			},
+			expect: []string{
+				"ReadTimeout: validate ltfield=WriteTimeout: invalid value 2s",
+				"WriteTimeout: validate gtefield=ReadTimeout: invalid value 1s",
+				"MinConns: validate ltefield=Limits.MaxConns: invalid value 2",
+				`TLS.CertFile: validate required_if=Enabled true: invalid value ""`,
+				"TLS.Insecure: validate excluded_with=CertFile KeyFile: invalid value true",
+				"Unknown: validate required_with=Missing: field Missing is not found",
+				"BadPair: validate required_if=Enabled: field and value pairs are expected",
+				"BadType: validate ltfield=MinConns: fields of string and int types could not be compared",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := reflect.ValueOf(&tt.value).Elem()
+
+			var actual []string
+			for _, err := range validateField(reflect.Value{}, "", reflect.StructField{}, v) {
+				actual = append(actual, err.Error())
+			}
+			assert.Equal(t, tt.expect, actual)
+		})
+	}
+}
+
+var errEmptyHost = errors.New("host is empty")
+
+type validatedReplica struct {
+	Host string
+}
+
+func (r validatedReplica) Validate() error {
+	if r.Host == "" {
+		return errEmptyHost
+	}
+	return nil
+}
+
+type validatedPostgres struct {
+	Primary  validatedReplica
+	Replicas []validatedReplica `envprefix:"VALIDATOR_REPLICAS"`
+	calls    *[]string
+}
+
+func (p *validatedPostgres) Validate() error {
+	*p.calls = append(*p.calls, "postgres")
+	return errors.New("postgres is invalid")
+}
+
+type validatedConfig struct {
+	Postgres validatedPostgres
+	Pools    map[string]*validatedReplica
+	calls    *[]string
+}
+
+func (c validatedConfig) Validate() error {
+	*c.calls = append(*c.calls, "config")
+	return nil
+}
+
+func TestInitValidator(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	cfg := validatedConfig{
+		Postgres: validatedPostgres{Primary: validatedReplica{Host: "primary"}, calls: &calls},
+		Pools:    map[string]*validatedReplica{"orders": {}},
+		calls:    &calls,
+	}
+
+	err := Init(&cfg, "", WithEnv(MapEnv{"VALIDATOR_REPLICAS_LEN": "1"}))
+	assert.EqualError(t, err, "Postgres.Replicas[0]: validate: host is empty; Postgres: validate: postgres is invalid; "+
+		"Pools[orders]: validate: host is empty")
+	assert.Equal(t, []string{"postgres", "config"}, calls)
+	assert.True(t, errors.Is(err, errEmptyHost))
+}
+
+type validatedPool struct {
+	Size int
+}
+
+func (p *validatedPool) Validate() error {
+	if p.Size == 0 {
+		return errors.New("size is empty")
+	}
+	return nil
+}
+
+func TestInitValidatorOfMapValues(t *testing.T) {
+	t.Parallel()
+
+	cfg := struct {
+		Pools map[string]validatedPool
+	}{
+		Pools: map[string]validatedPool{"orders": {}, "users": {Size: 1}},
+	}
+
+	err := Init(&cfg, "", WithEnv(MapEnv{}))
+	assert.EqualError(t, err, "Pools[orders]: validate: size is empty")
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultDebounce     = 500 * time.Millisecond
+)
+
+// WatchOption configures Watcher
+type WatchOption func(*Watcher)
+
+// WithPollInterval sets how often config file is checked for changes, it is 1s by default
+func WithPollInterval(interval time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.pollInterval = interval
+	}
+}
+
+// WithDebounce sets how long config file should stay unchanged before it is reloaded,
+// so file, which is being written, is not loaded half-way. It is 500ms by default
+func WithDebounce(debounce time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.debounce = debounce
+	}
+}
+
+// WithErrorHook receives errors of reloads, which are triggered by changes of config file,
+// and panics of change subscribers
+func WithErrorHook(hook func(err error)) WatchOption {
+	return func(w *Watcher) {
+		w.errorHook = hook
+	}
+}
+
+// Watcher reloads config when its file changes. Every reload runs the whole `Init` pipeline on a new value:
+// defaults, file, ENV and validation. The value is published as a snapshot, only when it is loaded without errors,
+// otherwise the previous snapshot is kept
+type Watcher struct {
+	filename     string
+	typ          reflect.Type
+	opts         []Option
+	provenance   bool
+	warningHook  WarningHook
+	pollInterval time.Duration
+	debounce     time.Duration
+	errorHook    func(err error)
+
+	// mu serialises reloads, changes are queued before mu is released, so they are notified in order of snapshots
+	mu       sync.Mutex
+	snapshot atomic.Value
+	file     os.FileInfo
+
+	// notifyMu guards queue of changes, which is drained by the reload, which finds it idle
+	notifyMu  sync.Mutex
+	pending   []notification
+	notifying bool
+
+	subscriptionsMu sync.Mutex
+	subscriptions   []subscription
+}
+
+// snapshot is loaded config with provenance of its fields, which is recorded, when `WithProvenance` option is passed
+type snapshot struct {
+	config     interface{}
+	provenance *Provenance
+}
+
+// notification is a pair of snapshots, which subscribers are notified of
+type notification struct {
+	old, new interface{}
+}
+
+// NewWatcher loads config from file `filename` into `config`, which must be a reference of struct,
+// the same way `Init` does. `config` becomes the first snapshot, `opts` are applied on every reload.
+// Provenance passed by `WithProvenance` option describes the first snapshot only, every reload records its own one
+func NewWatcher(config interface{}, filename string, opts []Option, watchOpts ...WatchOption) (*Watcher, error) {
+	l := newLoader(opts...)
+	w := &Watcher{
+		filename:     filename,
+		opts:         opts,
+		provenance:   l.provenance != nil,
+		warningHook:  l.warningHook,
+		pollInterval: defaultPollInterval,
+		debounce:     defaultDebounce,
+	}
+	for _, opt := range watchOpts {
+		opt(w)
+	}
+
+	// file is checked before loading, so that changes made while it is loaded are not missed
+	file, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	w.file = file
+
+	if err := Init(config, filename, opts...); err != nil {
+		return nil, err
+	}
+
+	w.typ = reflect.TypeOf(config).Elem()
+	w.snapshot.Store(&snapshot{config: config, provenance: l.provenance})
+	return w, nil
+}
+
+// Config returns the latest snapshot. It is a pointer of the same type as `config` passed to `NewWatcher`,
+// e.g. `*Config` for `&cfg`, so it is asserted as `w.Config().(*Config)`. Snapshot is shared, so it must not be modified
+func (w *Watcher) Config() interface{} {
+	return w.snapshot.Load().(*snapshot).config
+}
+
+// Provenance returns origins of fields of the latest snapshot, it is nil, when `WithProvenance` option is not passed
+func (w *Watcher) Provenance() *Provenance {
+	return w.snapshot.Load().(*snapshot).provenance
+}
+
+// Reload loads config into a new value and publishes it as a snapshot, when it is loaded without errors.
+// Error is returned and the previous snapshot is kept otherwise. Subscribers are notified of changes
+// once the snapshot is published, in order of snapshots. Reload, which runs while subscribers are notified
+// of another one, e.g. concurrent one or one called by subscriber, queues its changes and returns without waiting for them
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	next := &snapshot{config: reflect.New(w.typ).Interface()}
+	opts := w.opts
+	if w.provenance {
+		// provenance of published snapshot is not reset by reloads, which might fail
+		next.provenance = &Provenance{}
+		opts = append(append([]Option(nil), w.opts...), WithProvenance(next.provenance))
+	}
+	if err := Init(next.config, w.filename, opts...); err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	old := w.snapshot.Load().(*snapshot)
+	w.snapshot.Store(next)
+
+	w.notifyMu.Lock()
+	w.pending = append(w.pending, notification{old: old.config, new: next.config})
+	drain := !w.notifying
+	w.notifying = true
+	w.notifyMu.Unlock()
+	w.mu.Unlock()
+
+	if drain {
+		w.drain()
+	}
+	return nil
+}
+
+// drain notifies subscribers of queued changes one by one, until queue is empty.
+// No lock is held while subscribers run, so they might reload config
+func (w *Watcher) drain() {
+	for {
+		w.notifyMu.Lock()
+		if len(w.pending) == 0 {
+			w.notifying = false
+			w.notifyMu.Unlock()
+			return
+		}
+		n := w.pending[0]
+		w.pending = w.pending[1:]
+		w.notifyMu.Unlock()
+
+		w.notify(n.old, n.new)
+	}
+}
+
+// OnChange subscribes `fn` to changes of any field of config
+func (w *Watcher) OnChange(fn ChangeHandler) {
+	w.Subscribe("", fn)
+}
+
+// Subscribe subscribes `fn` to changes of field stored under `path` of field names and fields nested in it,
+// e.g. `Redis.Addrs` or `Postgres.Replicas`. `fn` receives only changes of the field
+func (w *Watcher) Subscribe(path string, fn ChangeHandler) {
+	w.subscriptionsMu.Lock()
+	defer w.subscriptionsMu.Unlock()
+
+	w.subscriptions = append(w.subscriptions, subscription{path: path, fn: fn})
+}
+
+// notify calls subscribers in order they subscribed, panic of subscriber is passed to error hook
+// or to warning hook, when error hook is not set, so other subscribers are still notified
+func (w *Watcher) notify(old, new interface{}) {
+	w.subscriptionsMu.Lock()
+	subscriptions := append([]subscription(nil), w.subscriptions...)
+	w.subscriptionsMu.Unlock()
+
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	changes := changesOf(old, new)
+	for _, s := range subscriptions {
+		var matched []Change
+		for _, c := range changes {
+			if s.matches(c.Path) {
+				matched = append(matched, c)
+			}
+		}
+		if len(matched) > 0 {
+			w.call(s, old, new, matched)
+		}
+	}
+}
+
+func (w *Watcher) call(s subscription, old, new interface{}, changes []Change) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		name := "subscriber of changes"
+		if s.path != "" {
+			name = fmt.Sprintf("subscriber of %s", s.path)
+		}
+		switch {
+		case w.errorHook != nil:
+			w.errorHook(fmt.Errorf("%s panicked: %v", name, r))
+		case w.warningHook != nil:
+			w.warningHook(fmt.Sprintf("%s panicked: %v", name, r))
+		}
+	}()
+
+	s.fn(old, new, changes)
+}
+
+// Watch checks config file for changes by its modification time, size and identity, until `ctx` is done.
+// File is reloaded, once it stays unchanged during debounce, it is checked again, when debounce is over, as debounce
+// might be shorter than poll interval. Errors of reloads are passed to error hook
+func (w *Watcher) Watch(ctx context.Context) error {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if w.changed() {
+				debounce = time.After(w.debounce)
+			}
+		case <-debounce:
+			if w.changed() {
+				debounce = time.After(w.debounce)
+				continue
+			}
+			debounce = nil
+			if err := w.Reload(); err != nil && w.errorHook != nil {
+				w.errorHook(err)
+			}
+		}
+	}
+}
+
+// changed reports whether config file changed since it was checked last time,
+// file replaced by another one, e.g. by update of symlink, is changed as well
+func (w *Watcher) changed() bool {
+	file, err := os.Stat(w.filename)
+	if err != nil {
+		// file, which is being replaced, might be missing for a moment, its next version is reloaded
+		return false
+	}
+
+	previous := w.file
+	w.file = file
+	return previous == nil ||
+		!file.ModTime().Equal(previous.ModTime()) ||
+		file.Size() != previous.Size() ||
+		!os.SameFile(file, previous)
+}
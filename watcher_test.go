@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type watchedConfig struct {
+	LogLevel  string `json:"log_level"  default:"info" validate:"oneof=debug info error"`
+	RateLimit int    `json:"rate_limit" envconfig:"WATCH_RATE_LIMIT"`
+}
+
+func TestWatcherReload(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{"rate_limit": 10}`})
+
+	var cfg watchedConfig
+	w, err := NewWatcher(&cfg, filename, []Option{WithEnv(MapEnv{})})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &watchedConfig{LogLevel: "info", RateLimit: 10}, w.Config())
+
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{"log_level": "debug", "rate_limit": 20}`})
+	assert.NoError(t, w.Reload())
+	assert.Equal(t, &watchedConfig{LogLevel: "debug", RateLimit: 20}, w.Config())
+	assert.Equal(t, watchedConfig{LogLevel: "info", RateLimit: 10}, cfg, "previous snapshot should not be modified")
+
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{"log_level": "trace", "rate_limit": 30}`})
+	err = w.Reload()
+	assert.True(t, errors.Is(err, ErrInvalid))
+	assert.Equal(t, &watchedConfig{LogLevel: "debug", RateLimit: 20}, w.Config(), "previous snapshot should be kept")
+
+	t.Run("provenance of snapshot", func(t *testing.T) {
+		var (
+			cfg watchedConfig
+			p   Provenance
+		)
+		helperWriteFiles(t, dir, map[string]string{"provenance.json": `{"rate_limit": 10}`})
+		filename := filepath.Join(dir, "provenance.json")
+
+		w, err := NewWatcher(&cfg, filename, []Option{WithEnv(MapEnv{}), WithProvenance(&p)})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, &p, w.Provenance())
+
+		helperWriteFiles(t, dir, map[string]string{"provenance.json": `{"rate_limit": 20}`})
+		assert.NoError(t, w.Reload())
+		origin, _ := w.Provenance().Explain("RateLimit")
+		assert.Equal(t, "20", origin.Value)
+		origin, _ = p.Explain("RateLimit")
+		assert.Equal(t, "10", origin.Value, "provenance of the first snapshot should not be modified")
+
+		helperWriteFiles(t, dir, map[string]string{"provenance.json": `{"rate_limit": "30"}`})
+		assert.Error(t, w.Reload())
+		origin, _ = w.Provenance().Explain("RateLimit")
+		assert.Equal(t, "20", origin.Value, "provenance of the previous snapshot should be kept")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var cfg watchedConfig
+		w, err := NewWatcher(&cfg, filepath.Join(dir, "missing.json"), []Option{WithEnv(MapEnv{})})
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, w)
+	})
+
+	t.Run("invalid initial config", func(t *testing.T) {
+		var cfg watchedConfig
+		w, err := NewWatcher(&cfg, filename, []Option{WithEnv(MapEnv{})})
+		assert.Error(t, err)
+		assert.Nil(t, w)
+	})
+}
+
+func TestWatcherWatch(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+	helperWriteFiles(t, dir, map[string]string{"config.json": `{"rate_limit": 10}`})
+
+	var (
+		mu     sync.Mutex
+		errs   []error
+		config watchedConfig
+	)
+	w, err := NewWatcher(&config, filename, []Option{WithEnv(MapEnv{})},
+		WithPollInterval(5*time.Millisecond),
+		WithDebounce(20*time.Millisecond),
+		WithErrorHook(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(ctx)
+	}()
+
+	// modification time is moved forward, as file system might not track it precisely
+	write := func(content string, mtime time.Time) {
+		helperWriteFiles(t, dir, map[string]string{"config.json": content})
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write(`{"rate_limit": 20}`, now.Add(time.Second))
+	assert.Eventually(t, func() bool {
+		return w.Config().(*watchedConfig).RateLimit == 20
+	}, time.Second, 5*time.Millisecond)
+
+	write(`{"log_level": "trace"}`, now.Add(2*time.Second))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, &watchedConfig{LogLevel: "info", RateLimit: 20}, w.Config())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}